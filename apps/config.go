@@ -0,0 +1,79 @@
+package apps
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/B9O2/mtmonitor/runtime"
+	"github.com/B9O2/tabby"
+	"github.com/BurntSushi/toml"
+)
+
+type ConfigApp struct {
+	*tabby.BaseApplication
+}
+
+func (ca *ConfigApp) Detail() (string, string) {
+	return "config", "configuration tools"
+}
+
+func (ca *ConfigApp) Main(args tabby.Arguments) (*tabby.TabbyContainer, error) {
+	ca.Help("Multitasking Monitor Configuration")
+	return nil, nil
+}
+
+func NewConfigApp(apps ...tabby.Application) *ConfigApp {
+	return &ConfigApp{
+		tabby.NewBaseApplication(false, apps),
+	}
+}
+
+type ConfigCheckApp struct {
+	*tabby.BaseApplication
+}
+
+func (cca *ConfigCheckApp) Detail() (string, string) {
+	return "check", "validate configuration file"
+}
+
+func (cca *ConfigCheckApp) Main(args tabby.Arguments) (*tabby.TabbyContainer, error) {
+	configPath := args.Get("config").(string)
+	var errs runtime.ValidationErrors
+	cfg, err := runtime.LoadConfig(configPath)
+	if err != nil {
+		// 变量展开与继承阶段的错误同样逐项列出
+		if !errors.As(err, &errs) {
+			return nil, err
+		}
+	} else {
+		errs = cfg.Validate()
+	}
+
+	if len(errs) > 0 {
+		for _, ve := range errs {
+			fmt.Printf("[x]%s\n", ve)
+		}
+		return nil, fmt.Errorf("%d problem(s) found in '%s'", len(errs), configPath)
+	}
+
+	if args.Get("show").(bool) {
+		// 打印展开变量、合并继承后的生效配置，敏感值已隐藏
+		if err := toml.NewEncoder(os.Stdout).Encode(cfg.Redacted()); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("[-]Config '%s' is valid: %d core(s), %d credential(s).\n",
+		configPath, len(cfg.Cores), len(cfg.Credentials))
+	return nil, nil
+}
+
+func NewConfigCheckApp() *ConfigCheckApp {
+	app := &ConfigCheckApp{
+		tabby.NewBaseApplication(false, nil),
+	}
+	app.SetParam("config", "configuration file path", tabby.String("config.toml"), "c")
+	app.SetParam("show", "print the resolved config with secrets redacted", tabby.Bool(false), "s")
+	return app
+}
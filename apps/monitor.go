@@ -14,10 +14,7 @@ import (
 	"github.com/B9O2/tabby"
 	"github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 )
 
@@ -34,29 +31,30 @@ func (ba *MonitorApp) Main(
 ) (*tabby.TabbyContainer, error) {
 	addr := args.Get("addr").(string)
 	interval := args.Get("interval").(time.Duration)
-	certPath := args.Get("cert-path").(string)
+	cred := runtime.CredentialConfig{
+		Path:               args.Get("cert-path").(string),
+		Cert:               args.Get("client-cert").(string),
+		Key:                args.Get("client-key").(string),
+		ServerName:         args.Get("server-name").(string),
+		InsecureSkipVerify: args.Get("insecure-skip-verify").(bool),
+		MinVersion:         args.Get("tls-min-version").(string),
+	}
 
 	if err := termui.Init(); err != nil {
 		panic("failed to initialize termui: " + err.Error())
 	}
 	defer termui.Close()
 
-	var creds credentials.TransportCredentials
-	var err error
-	var opts []grpc.DialOption
-	if certPath != "" {
-		creds, err = credentials.NewClientTLSFromFile(certPath, "localhost")
-		if err != nil {
-			return nil, err
-		}
-		fmt.Printf("[-]Using credential '%s'.\n", certPath)
+	opts, err := cred.DialOptions()
+	if err != nil {
+		return nil, err
+	}
+	if cred.TLSEnabled() {
+		fmt.Printf("[-]Using TLS credential '%s'.\n", cred.Path)
 	} else {
-		creds = insecure.NewCredentials()
 		fmt.Println("[!]Ignore credential.")
 	}
 
-	opts = append(opts, grpc.WithTransportCredentials(creds))
-
 	mc, err := monitor_core.NewMonitorClient(addr, opts...)
 	if err != nil {
 		return nil, err
@@ -74,6 +72,15 @@ func (ba *MonitorApp) Main(
 		return nil, err
 	}
 
+	collector, err := core.NewCollector(addr, interval, runtime.CoreConfig{
+		HealthCheck: runtime.HealthCheckConfig{
+			MinUsageRate: 0.1,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// 滚动日志区域
 	logBox := widgets.NewList()
 	logBox.Title = "Logs"
@@ -122,7 +129,6 @@ func (ba *MonitorApp) Main(
 	termWidth, termHeight := termui.TerminalDimensions()
 	grid.SetRect(0, 0, termWidth, termHeight)
 
-	var lastMetrics *core.Metrics
 	loop := true
 	for loop {
 		select {
@@ -141,6 +147,7 @@ func (ba *MonitorApp) Main(
 				loop = false
 				break
 			}
+			collector.Observe(e)
 			logBox.Rows = append(logBox.Rows, e.Logs...)
 			logBox.ScrollBottom()
 		case s := <-statusChan:
@@ -149,11 +156,7 @@ func (ba *MonitorApp) Main(
 				break
 			}
 			//fmt.Println("Reading...")
-			metrics := core.NewMetrics(s, lastMetrics, interval, runtime.HealthCheckConfig{
-				MaxWorkingIntervalTimes: 3,
-				MinUsageRate:            0.1,
-			})
-			lastMetrics = metrics
+			metrics := collector.Update(s)
 
 			//fmt.Print(metrics, "\n\n")
 
@@ -191,7 +194,12 @@ func NewMonitorApp(apps ...tabby.Application) *MonitorApp {
 		tabby.NewBaseApplication(false, apps),
 	}
 	ba.SetParam("addr", "address", tabby.String(nil), "a")
-	ba.SetParam("cert-path", "cert path", tabby.String(""), "cp")
+	ba.SetParam("cert-path", "CA cert path", tabby.String(""), "cp")
+	ba.SetParam("client-cert", "client cert path for mutual TLS", tabby.String(""), "cc")
+	ba.SetParam("client-key", "client key path for mutual TLS", tabby.String(""), "ck")
+	ba.SetParam("server-name", "TLS server name", tabby.String(""), "sn")
+	ba.SetParam("insecure-skip-verify", "skip TLS certificate verification", tabby.Bool(false), "k")
+	ba.SetParam("tls-min-version", "minimum TLS version (1.0-1.3)", tabby.String(""), "tv")
 	ba.SetParam("interval", "message interval", tabby.Duration(time.Second), "i")
 	return ba
 }
@@ -1,9 +1,18 @@
 package apps
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
+	"github.com/B9O2/mtmonitor/core"
 	"github.com/B9O2/mtmonitor/runtime"
 	"github.com/B9O2/mtmonitor/web"
 	"github.com/B9O2/tabby"
@@ -23,35 +32,97 @@ func (wma *WebMonitorApp) Main(args tabby.Arguments) (*tabby.TabbyContainer, err
 		wma.Help("Multitasking Web Monitor")
 		return nil, nil
 	}
+	// 收到中断信号后停止服务，关闭核心与指标存储
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	host := args.Get("server").(string)
 	port := args.Get("port").(int)
-	cfg, err := runtime.LoadConfig(args.Get("config").(string))
+	configPath := args.Get("config").(string)
+	cfg, err := runtime.LoadConfig(configPath)
 	if err != nil {
 		return nil, err
 	}
+	if errs := cfg.Validate(); len(errs) > 0 {
+		return nil, errs
+	}
 
 	server := web.NewMonitorWebServer(web.NewCredentials(cfg.Credentials), wma.subFS)
-	for name, core := range cfg.Cores {
+	persister, err := runtime.NewPersister(configPath, cfg.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	if persister != nil {
+		server.SetPersister(persister)
+		fmt.Printf("[-]Persisting API changes in '%s' mode.\n", persister.Mode())
+	}
+
+	store, err := core.OpenStore(configPath, cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if store != nil {
+		server.SetStore(store)
+		fmt.Printf("[-]Storing metrics history in '%s'.\n", cfg.Storage.Path(configPath))
+		go store.Run(ctx, cfg.Storage.CompactIntervalDuration(), func(err error) {
+			if err != nil {
+				fmt.Printf("[!]Metrics storage compaction failed: %v\n", err)
+			}
+		})
+	}
 
-		err = server.AddCore(name, core)
-		if err != nil {
-			return nil, err
+	exporter, err := core.NewOTLPExporter(cfg.Exporters.OTLP)
+	if err != nil {
+		return nil, err
+	}
+	if exporter != nil {
+		server.SetExporter(exporter)
+		fmt.Printf("[-]Exporting metrics and logs to OTLP collector %s over %s.\n",
+			cfg.Exporters.OTLP.Endpoint, cfg.Exporters.OTLP.ProtocolOrDefault())
+		// 导出器在服务停止、核心全部移除之后才停止，以便导出最后的数据
+		exportCtx, stopExport := context.WithCancel(context.Background())
+		go exporter.Run(exportCtx, func(err error) {
+			fmt.Printf("[!]OTLP export: %v\n", err)
+		})
+		defer func() {
+			stopExport()
+			if err := exporter.Wait(); err != nil {
+				fmt.Printf("[!]OTLP export: %v\n", err)
+			}
+		}()
+	}
+
+	report := server.ApplyConfig(cfg)
+	if len(report.Failed) > 0 {
+		var errs []error
+		for _, name := range slices.Sorted(maps.Keys(report.Failed)) {
+			errs = append(errs, fmt.Errorf("core '%s': %s", name, report.Failed[name]))
 		}
-		fmt.Printf("[-]Core '%s' added with host %s:%d and interval %s.\n",
-			name, core.Host, core.Port, core.Interval)
+		return nil, errors.Join(append(errs, server.Close())...)
 	}
+	for _, name := range report.Added {
+		core := cfg.Cores[name]
+		address, _ := core.Address()
+		fmt.Printf("[-]Core '%s' added with target %s and interval %s.\n",
+			name, address, core.Interval)
+	}
+
+	// 监听配置文件变化，热重载核心
+	watcher := runtime.NewConfigWatcher(configPath, args.Get("reload-interval").(time.Duration))
+	go watcher.Watch(ctx, server.Reload)
 
-	return nil, server.Start(host, port)
+	return nil, server.Start(ctx, host, port)
 }
 
-func NewWebMonitorApp(subFS fs.FS) *WebMonitorApp {
+func NewWebMonitorApp(subFS fs.FS, apps ...tabby.Application) *WebMonitorApp {
 	app := &WebMonitorApp{
-		BaseApplication: tabby.NewBaseApplication(false, nil),
+		BaseApplication: tabby.NewBaseApplication(false, apps),
 		subFS:           subFS,
 	}
 	app.SetParam("server", "web server host", tabby.String("0.0.0.0"), "s")
 	app.SetParam("port", "web server port", tabby.Int(9783), "p")
 	app.SetParam("config", "configuration file path", tabby.String("config.toml"), "c")
+	app.SetParam("reload-interval", "config file polling interval, 0 to reload on SIGHUP only", tabby.Duration(2*time.Second), "ri")
 	app.SetParam("help", "show help", tabby.Bool(false), "h")
 	return app
 }
@@ -4,6 +4,7 @@ import (
 	"embed"
 	"fmt"
 	"io/fs"
+	"os"
 
 	"github.com/B9O2/mtmonitor/apps"
 
@@ -19,11 +20,14 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create sub filesystem: %v", err))
 	}
-	t := tabby.NewTabby("Monitor", apps.NewWebMonitorApp(subFS))
+	t := tabby.NewTabby("Monitor", apps.NewWebMonitorApp(subFS,
+		apps.NewMonitorApp(),
+		apps.NewConfigApp(apps.NewConfigCheckApp()),
+	))
 	tc, err := t.Run(nil)
 	if err != nil {
 		fmt.Printf("[x]Error: %s\n", err)
-		return
+		os.Exit(1)
 	}
 
 	if tc != nil {
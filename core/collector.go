@@ -0,0 +1,243 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+// Collector 每个核心一个实例，重连后继续使用
+type Collector struct {
+	name     string
+	interval time.Duration
+	config   runtime.HealthCheckConfig
+	window   time.Duration
+	rules    []HealthRule
+	threads  ThreadTracker
+	issues   *IssueTracker
+	severity map[string]runtime.SeverityConfig
+	health   CoreHealth
+
+	lock       sync.Mutex
+	last       *Metrics // 本次连接的上一帧，重连后为 nil
+	latest     *Metrics // 最近一帧，重连后保留，用于发现核心重启
+	runStarted time.Time
+	runs       []RunSummary
+	speed      *speedTracker
+	series     *History // 供查询的指标历史，与规则使用的 history 窗口相互独立
+	completed  bool
+	history    []*Metrics
+	errorLogs  atomic.Int64
+	now        func() time.Time
+}
+
+func (c *Collector) Observe(events *monitor.Events) {
+	for _, line := range events.Logs {
+		if ParseLog(line).IsError() {
+			c.errorLogs.Add(1)
+		}
+	}
+}
+
+// Reset 在新连接建立时调用，下一帧不再与断线前的数据计算差值。
+// 线程的忙碌起始时间会保留，重连前后处理同一任务的线程继续计时。
+func (c *Collector) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.last = nil
+	c.speed.ResetPeak()
+}
+
+func (c *Collector) Update(status *monitor.Status) *Metrics {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	metrics := newMetricsAt(status, c.last, c.interval, c.now())
+	if c.latest == nil {
+		c.runStarted = metrics.Time
+	} else if counters := resetCounters(c.latest.Status, status); len(counters) > 0 {
+		previous := newRunSummary(c.runStarted, c.latest)
+		c.runs = append(c.runs, previous)
+		if len(c.runs) > maxRunSummaries {
+			c.runs = c.runs[len(c.runs)-maxRunSummaries:]
+		}
+		c.runStarted = metrics.Time
+		metrics.CoreEvents = append(metrics.CoreEvents, CoreEvent{
+			Type: "core_restarted",
+			Data: CoreRestart{Previous: previous, Counters: counters},
+		})
+	}
+	busySince, resize := c.threads.Update(status, metrics.Time)
+	metrics.ThreadsBusySince = busySince
+	if resize != nil {
+		metrics.CoreEvents = append(metrics.CoreEvents, CoreEvent{Type: "pool_resized", Data: *resize})
+	}
+	var prev *monitor.Status
+	if c.last != nil {
+		prev = c.last.Status
+	}
+	threadStats := NewThreadStats(status, prev, c.interval)
+	metrics.ThreadsRate = threadStats.Rates
+	metrics.ThreadsShare = threadStats.Shares
+	metrics.Imbalance = threadStats.Imbalance
+	metrics.HotThreads = threadStats.Hot
+	metrics.ColdThreads = threadStats.Cold
+	for tid, since := range metrics.ThreadsBusySince {
+		if since != nil && c.interval > 0 {
+			metrics.ThreadsWorkingTimes[tid] = uint(metrics.Time.Sub(*since) / c.interval)
+		}
+	}
+	c.estimate(metrics)
+	if metrics.Completed && !c.completed {
+		metrics.CoreEvents = append(metrics.CoreEvents, CoreEvent{
+			Type: "job_completed",
+			Data: JobCompletion{
+				CompletedAt: metrics.Time,
+				Duration:    metrics.Time.Sub(c.runStarted).Seconds(),
+				TotalTask:   uint64(metrics.TotalTask),
+				TotalResult: uint64(metrics.TotalResult),
+				TotalRetry:  uint64(metrics.TotalRetry),
+			},
+		})
+	}
+	c.completed = metrics.Completed
+
+	if c.interval > 0 {
+		metrics.ErrorLogRate = float64(c.errorLogs.Swap(0)) / c.interval.Seconds()
+	}
+
+	c.history = append(c.history, metrics)
+	for len(c.history) > 1 && metrics.Time.Sub(c.history[0].Time) > c.window {
+		c.history[0] = nil
+		c.history = c.history[1:]
+	}
+	metrics.RetryQueueTrend = retryQueueTrend(c.history)
+
+	ctx := &RuleContext{
+		Core:     c.name,
+		Current:  metrics,
+		Previous: c.last,
+		History:  c.history,
+		Interval: c.interval,
+		Config:   c.config,
+		Runs:     c.runs,
+	}
+	for _, rule := range c.rules {
+		metrics.HealthIssues = append(metrics.HealthIssues, rule.Evaluate(ctx)...)
+	}
+	for i := range metrics.HealthIssues {
+		c.escalate(&metrics.HealthIssues[i], metrics.Time)
+	}
+	metrics.CoreEvents = append(metrics.CoreEvents, c.issues.Observe(metrics.HealthIssues, metrics.Time)...)
+	metrics.CoreEvents = append(metrics.CoreEvents, c.healthChanged()...)
+
+	if err := c.series.Add(metrics); err != nil {
+		metrics.CoreEvents = append(metrics.CoreEvents, CoreEvent{Type: "storage_error", Data: err.Error()})
+	}
+	c.last = metrics
+	c.latest = metrics
+	return metrics
+}
+
+// 重连后的首帧没有速度，不计入速度统计
+func (c *Collector) estimate(metrics *Metrics) {
+	if c.last != nil {
+		c.speed.Push(metrics.Time, metrics.Speed)
+	}
+	metrics.SpeedEWMA = c.speed.ewma
+	metrics.SpeedStats = c.speed.Stats(metrics.Time)
+	switch {
+	case metrics.Pending == 0 && metrics.TotalTask > 0:
+		eta := 0.0
+		metrics.ETA = &eta
+	case metrics.SpeedEWMA > 0:
+		eta := float64(metrics.Pending) / metrics.SpeedEWMA
+		metrics.ETA = &eta
+	}
+}
+
+// escalate 按问题持续的周期数升级严重程度。两个阈值都从问题出现时算起，
+// 而不是从上一次升级算起：warning_after = 3、critical_after = 30 即持续 30 个周期后为 critical。
+func (c *Collector) escalate(issue *HealthIssue, now time.Time) {
+	sc := c.severity[issue.Type]
+	if sc.Severity != "" {
+		issue.Severity = Severity(sc.Severity)
+	}
+	intervals := uint(1)
+	if since, ok := c.issues.Since(IssueFingerprint(c.name, *issue)); ok && c.interval > 0 {
+		intervals += uint(now.Sub(since) / c.interval)
+	}
+	if sc.CriticalAfter > 0 && intervals >= sc.CriticalAfter {
+		issue.Severity = SeverityCritical
+	} else if sc.WarningAfter > 0 && intervals >= sc.WarningAfter && !issue.Severity.AtLeast(SeverityWarning) {
+		issue.Severity = SeverityWarning
+	}
+	issue.Alert = issue.Severity == SeverityCritical
+}
+
+func (c *Collector) healthChanged() []CoreEvent {
+	summary := c.issues.Summary()
+	if summary.Health == c.health {
+		return nil
+	}
+	c.health = summary.Health
+	return []CoreEvent{{Type: "core_health", Data: summary}}
+}
+
+func (c *Collector) RaiseIssue(issue HealthIssue) (HealthIssue, []CoreEvent) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	c.escalate(&issue, now)
+	issue.ID = IssueFingerprint(c.name, issue)
+	return issue, append(c.issues.Raise(issue, now), c.healthChanged()...)
+}
+
+func (c *Collector) Issues(state IssueState, min Severity) []TrackedIssue {
+	return c.issues.Issues(state, min)
+}
+
+func (c *Collector) Runs() []RunSummary {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append(make([]RunSummary, 0, len(c.runs)), c.runs...)
+}
+
+// Latest 返回最近一帧，尚未收到数据时为 nil；重连后保留断线前的最后一帧
+func (c *Collector) Latest() *Metrics {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.latest
+}
+
+func (c *Collector) History() *History {
+	return c.series
+}
+
+func (c *Collector) Health() HealthSummary {
+	return c.issues.Summary()
+}
+
+func NewCollector(name string, interval time.Duration, cc runtime.CoreConfig) (*Collector, error) {
+	cfg := cc.HealthCheck
+	rules, err := NewRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Collector{
+		name:     name,
+		interval: interval,
+		config:   cfg,
+		window:   cfg.WindowDuration(),
+		rules:    rules,
+		issues:   NewIssueTracker(name),
+		severity: severityConfigs(cfg),
+		health:   HealthOK,
+		speed:    newSpeedTracker(interval, cc.Stats.WindowDuration()),
+		series:   NewHistory(cc.History.Capacity(interval), cc.History.RetentionDuration()),
+		now:      time.Now,
+	}, nil
+}
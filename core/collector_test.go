@@ -0,0 +1,167 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+// testEpoch 测试中帧时间的起点
+var testEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeClock 每次调用 Update 前由测试设置的时间
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) advance(d time.Duration) {
+	fc.now = fc.now.Add(d)
+}
+
+// newTestCollector 使用默认健康检查配置创建收集器
+func newTestCollector(t *testing.T, interval time.Duration) (*Collector, *fakeClock) {
+	t.Helper()
+	return newCollectorWith(t, interval, runtime.HealthCheckConfig{})
+}
+
+// newCollectorWith 使用指定健康检查配置创建收集器
+func newCollectorWith(t *testing.T, interval time.Duration, hc runtime.HealthCheckConfig) (*Collector, *fakeClock) {
+	t.Helper()
+	c, err := NewCollector("a", interval, runtime.CoreConfig{HealthCheck: hc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: testEpoch}
+	c.now = func() time.Time { return clock.now }
+	return c, clock
+}
+
+// 帧提前或延迟到达时，各项速率都按采集周期计算
+func TestRates(t *testing.T) {
+	for _, gap := range []time.Duration{time.Second, 4 * time.Second, 500 * time.Millisecond} {
+		t.Run(gap.String(), func(t *testing.T) {
+			c, clock := newTestCollector(t, time.Second)
+			c.Update(newStatus(100, 10, 0, []int{1, 1}, []int{5, 5}))
+			clock.advance(gap)
+			for range 4 {
+				c.Observe(&monitor.Events{Logs: []string{`{"level":"ERROR","message":"boom"}`}})
+			}
+			m := c.Update(newStatus(100, 20, 2, []int{1, 1}, []int{12, 8}))
+
+			if m.Speed != 10 || m.RetryRate != 2 || m.ErrorLogRate != 4 {
+				t.Errorf("speed %g, retry rate %g, error log rate %g, want 10, 2, 4", m.Speed, m.RetryRate, m.ErrorLogRate)
+			}
+			// 各线程速率之和等于总速度
+			if !slices.Equal(m.ThreadsRate, []float64{7, 3}) {
+				t.Errorf("thread rates %v, want [7 3]", m.ThreadsRate)
+			}
+		})
+	}
+}
+
+func TestNewMetricsFirstFrame(t *testing.T) {
+	m := newMetricsAt(newStatus(10, 5, 1, []int{1}, []int{5}), nil, time.Second, time.Now())
+	if m.Speed != 0 {
+		t.Errorf("first frame speed = %g, want 0", m.Speed)
+	}
+	if m.Pending != 5 || m.ProgressPct != 50 {
+		t.Errorf("pending, progress = %d, %g", m.Pending, m.ProgressPct)
+	}
+}
+
+func TestETA(t *testing.T) {
+	tests := []struct {
+		name   string
+		task   uint64
+		result uint64
+		want   string // "nil"、"zero" 或 "estimate"
+	}{
+		{"no tasks yet", 0, 0, "nil"},
+		{"all done", 100, 100, "zero"},
+		{"pending", 100, 40, "estimate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, clock := newTestCollector(t, time.Second)
+			c.Update(newStatus(tt.task, 0, 0, []int{0}, []int{0}))
+			clock.advance(time.Second)
+			m := c.Update(newStatus(tt.task, tt.result, 0, []int{0}, []int{int(tt.result)}))
+			switch tt.want {
+			case "nil":
+				if m.ETA != nil {
+					t.Errorf("ETA = %g, want nil", *m.ETA)
+				}
+			case "zero":
+				if m.ETA == nil || *m.ETA != 0 {
+					t.Errorf("ETA = %v, want 0", m.ETA)
+				}
+			case "estimate":
+				if m.SpeedEWMA <= 0 || m.ETA == nil || *m.ETA != float64(m.Pending)/m.SpeedEWMA {
+					t.Errorf("ETA = %v with pending %d and speed %g", m.ETA, m.Pending, m.SpeedEWMA)
+				}
+			}
+		})
+	}
+}
+
+// healthEvents 返回事件中 core_health 的健康状态
+func healthEvents(events []CoreEvent) []CoreHealth {
+	var health []CoreHealth
+	for _, event := range events {
+		if summary, ok := event.Data.(HealthSummary); ok && event.Type == "core_health" {
+			health = append(health, summary.Health)
+		}
+	}
+	return health
+}
+
+func TestSeverityEscalation(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       runtime.HealthRuleConfig
+		severities []Severity
+		health     []CoreHealth // 每帧推送的健康状态，未变化时为空
+	}{
+		// critical_after 从问题出现时算起，而不是从升级为 warning 时算起
+		{"escalates over intervals", runtime.HealthRuleConfig{Severity: "info", WarningAfter: 2, CriticalAfter: 4},
+			[]Severity{SeverityInfo, SeverityWarning, SeverityWarning, SeverityCritical}, []CoreHealth{"", HealthDegraded, "", HealthDown}},
+		{"critical only", runtime.HealthRuleConfig{Severity: "info", CriticalAfter: 3},
+			[]Severity{SeverityInfo, SeverityInfo, SeverityCritical}, []CoreHealth{"", "", HealthDown}},
+		{"never lowered", runtime.HealthRuleConfig{Severity: "critical", WarningAfter: 1},
+			[]Severity{SeverityCritical, SeverityCritical}, []CoreHealth{HealthDown, ""}},
+		{"default warning", runtime.HealthRuleConfig{},
+			[]Severity{SeverityWarning, SeverityWarning}, []CoreHealth{HealthDegraded, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			rule.Name, rule.Metric, rule.Op = "always", "working", ">="
+			c, clock := newCollectorWith(t, time.Second, runtime.HealthCheckConfig{
+				MaxWorkingIntervalTimes: 3,
+				DisabledRules:           RegisteredRules(),
+				Rules:                   []runtime.HealthRuleConfig{rule},
+			})
+			for i, want := range tt.severities {
+				m := c.Update(newStatus(10, uint64(i), 0, []int{1}, []int{i}))
+				clock.advance(time.Second)
+				if len(m.HealthIssues) != 1 {
+					t.Fatalf("frame %d: issues %+v", i, m.HealthIssues)
+				}
+				got := m.HealthIssues[0]
+				if got.Severity != want || got.Alert != (want == SeverityCritical) {
+					t.Errorf("frame %d: severity %s, alert %v, want %s", i, got.Severity, got.Alert, want)
+				}
+				var health CoreHealth
+				if events := healthEvents(m.CoreEvents); len(events) > 0 {
+					health = events[len(events)-1]
+				}
+				if health != tt.health[i] {
+					t.Errorf("frame %d: pushed health %q, want %q", i, health, tt.health[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HistorySample 历史中保存的一帧，只保留绘图所需的数值
+type HistorySample struct {
+	Time        time.Time
+	Speed       float64
+	SpeedEWMA   float64
+	Working     float64
+	Idle        float64
+	Usage       float64
+	Pending     float64
+	TotalTask   float64
+	TotalResult float64
+	TotalRetry  float64
+	RetrySize   float64
+	RetryRate   float64
+}
+
+// historyField 可查询的字段，累计值降采样时取区间内最后一个值，其余取平均值
+type historyField struct {
+	name       string
+	cumulative bool
+	value      func(s *HistorySample) *float64
+}
+
+var historyFields = []historyField{
+	{"speed", false, func(s *HistorySample) *float64 { return &s.Speed }},
+	{"speed_ewma", false, func(s *HistorySample) *float64 { return &s.SpeedEWMA }},
+	{"working", false, func(s *HistorySample) *float64 { return &s.Working }},
+	{"idle", false, func(s *HistorySample) *float64 { return &s.Idle }},
+	{"usage", false, func(s *HistorySample) *float64 { return &s.Usage }},
+	{"pending", false, func(s *HistorySample) *float64 { return &s.Pending }},
+	{"total_task", true, func(s *HistorySample) *float64 { return &s.TotalTask }},
+	{"total_result", true, func(s *HistorySample) *float64 { return &s.TotalResult }},
+	{"total_retry", true, func(s *HistorySample) *float64 { return &s.TotalRetry }},
+	{"retry_size", false, func(s *HistorySample) *float64 { return &s.RetrySize }},
+	{"retry_rate", false, func(s *HistorySample) *float64 { return &s.RetryRate }},
+}
+
+func HistoryFields() []string {
+	names := make([]string, len(historyFields))
+	for i, f := range historyFields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// maxHistoryPoints 未指定 step 时每个序列返回的最大点数，超过时自动降采样
+const maxHistoryPoints = 1000
+
+// HistoryQuery 历史查询条件，Step 为 0 时返回原始数据（超过 maxHistoryPoints 时自动选择步长）
+type HistoryQuery struct {
+	From   time.Time
+	To     time.Time
+	Fields []string
+	Step   time.Duration
+}
+
+// HistorySeries 查询结果，Series 中每个序列与 Times 一一对应
+type HistorySeries struct {
+	From       time.Time            `json:"from"`
+	To         time.Time            `json:"to"`
+	Step       float64              `json:"step"`       // 降采样步长（秒），0 表示原始数据
+	Resolution string               `json:"resolution"` // 数据来源：memory 为内存中的历史，其余为持久化存储的分辨率
+	Times      []time.Time          `json:"times"`
+	Series     map[string][]float64 `json:"series"`
+}
+
+func NewHistorySample(m *Metrics) HistorySample {
+	return HistorySample{
+		Time:        m.Time,
+		Speed:       m.Speed,
+		SpeedEWMA:   m.SpeedEWMA,
+		Working:     float64(m.Working),
+		Idle:        float64(m.Idle),
+		Usage:       m.UsageRate(),
+		Pending:     float64(m.Pending),
+		TotalTask:   float64(m.TotalTask),
+		TotalResult: float64(m.TotalResult),
+		TotalRetry:  float64(m.TotalRetry),
+		RetrySize:   float64(m.RetrySize),
+		RetryRate:   m.RetryRate,
+	}
+}
+
+// History 单个核心的指标历史，按帧数与保留时长两者限制大小。
+// 设置存储后每帧同时写入存储，查询范围早于内存中的数据时从存储读取。
+type History struct {
+	lock      sync.RWMutex
+	samples   *Ring[HistorySample]
+	retention time.Duration
+	store     *SeriesStore
+	now       func() time.Time
+}
+
+func (h *History) SetStore(store *SeriesStore) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.store = store
+}
+
+// Add 追加一帧，返回写入存储时的错误
+func (h *History) Add(m *Metrics) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	sample := NewHistorySample(m)
+	h.samples.Push(sample)
+	if h.store == nil {
+		return nil
+	}
+	return h.store.Append(0, sample)
+}
+
+// Samples 返回内存中 [from, to] 内的原始帧，由旧到新，to 为零值时不限制结束时间
+func (h *History) Samples(from, to time.Time) []HistorySample {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	oldest := h.now().Add(-h.retention)
+	var samples []HistorySample
+	for i := 0; i < h.samples.Len(); i++ {
+		s := h.samples.At(i)
+		if s.Time.Before(oldest) || s.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && s.Time.After(to) {
+			break
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// covers 内存中的数据是否覆盖从 from 开始的范围，from 为零值时内存中有数据即视为覆盖
+func (h *History) covers(from time.Time) bool {
+	if h.samples.Len() == 0 {
+		return false
+	}
+	if from.IsZero() {
+		return true
+	}
+	oldest := h.now().Add(-h.retention)
+	return !from.Before(h.samples.At(0).Time) && !from.Before(oldest)
+}
+
+func (h *History) Query(q HistoryQuery) (*HistorySeries, error) {
+	h.lock.RLock()
+	store, covered := h.store, h.covers(q.From)
+	h.lock.RUnlock()
+
+	if store == nil || covered {
+		series, err := QuerySamples(h.Samples(q.From, q.To), q)
+		if err == nil {
+			series.Resolution = "memory"
+		}
+		return series, err
+	}
+
+	if q.From.IsZero() {
+		// 重启后内存中还没有数据，从存储中读取内存保留时长内的数据
+		q.From = h.now().Add(-h.retention)
+	}
+	resolution := store.resolutionFor(q.From, q.Step, h.now())
+	samples, err := store.Samples(resolution, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	series, err := QuerySamples(samples, q)
+	if err == nil {
+		series.Resolution = storeResolutions[resolution].name
+	}
+	return series, err
+}
+
+// downsample 按步长分桶聚合时间有序的帧，桶的时间为桶的起点
+func downsample(samples []HistorySample, step time.Duration) []HistorySample {
+	var buckets []HistorySample
+	for start := 0; start < len(samples); {
+		bucket := samples[start].Time.Truncate(step)
+		end := start + 1
+		for end < len(samples) && samples[end].Time.Truncate(step).Equal(bucket) {
+			end++
+		}
+		aggregated := HistorySample{Time: bucket}
+		for _, f := range historyFields {
+			value := f.value(&aggregated)
+			if f.cumulative {
+				*value = *f.value(&samples[end-1])
+				continue
+			}
+			for i := start; i < end; i++ {
+				*value += *f.value(&samples[i])
+			}
+			*value /= float64(end - start)
+		}
+		buckets = append(buckets, aggregated)
+		start = end
+	}
+	return buckets
+}
+
+// QuerySamples 对时间有序的原始帧按查询条件选取字段并降采样
+func QuerySamples(samples []HistorySample, q HistoryQuery) (*HistorySeries, error) {
+	fields := make([]historyField, 0, len(q.Fields))
+	for _, name := range q.Fields {
+		index := slices.IndexFunc(historyFields, func(f historyField) bool { return f.name == name })
+		if index == -1 {
+			return nil, fmt.Errorf("unknown field %q, expected one of %v", name, HistoryFields())
+		}
+		fields = append(fields, historyFields[index])
+	}
+	if len(fields) == 0 {
+		fields = historyFields
+	}
+	if q.Step < 0 {
+		return nil, fmt.Errorf("step must not be negative")
+	}
+
+	step := q.Step
+	if step == 0 && len(samples) > maxHistoryPoints {
+		step = max(samples[len(samples)-1].Time.Sub(samples[0].Time)/maxHistoryPoints, time.Millisecond)
+	}
+	if step > 0 {
+		samples = downsample(samples, step)
+	}
+
+	series := &HistorySeries{
+		From:   q.From,
+		To:     q.To,
+		Step:   step.Seconds(),
+		Times:  make([]time.Time, 0, len(samples)),
+		Series: make(map[string][]float64, len(fields)),
+	}
+	if series.From.IsZero() && len(samples) > 0 {
+		series.From = samples[0].Time
+	}
+	for _, f := range fields {
+		series.Series[f.name] = make([]float64, 0, len(samples))
+	}
+	for i := range samples {
+		series.Times = append(series.Times, samples[i].Time)
+		for _, f := range fields {
+			series.Series[f.name] = append(series.Series[f.name], *f.value(&samples[i]))
+		}
+	}
+	return series, nil
+}
+
+func NewHistory(capacity int, retention time.Duration) *History {
+	return &History{
+		samples:   NewRing[HistorySample](capacity),
+		retention: retention,
+		now:       time.Now,
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// historyAt 从 base 开始每秒一帧的历史，speed 与 total_result 取帧序号
+func historyAt(base time.Time, n int) []HistorySample {
+	samples := make([]HistorySample, n)
+	for i := range samples {
+		samples[i] = HistorySample{Time: base.Add(time.Duration(i) * time.Second), Speed: float64(i), TotalResult: float64(i)}
+	}
+	return samples
+}
+
+func TestQuerySamples(t *testing.T) {
+	base := testEpoch
+	samples := historyAt(base, 6)
+
+	raw, err := QuerySamples(samples, HistoryQuery{Fields: []string{"speed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw.Times) != 6 || raw.Step != 0 || len(raw.Series) != 1 {
+		t.Errorf("raw query = %+v", raw)
+	}
+
+	down, err := QuerySamples(samples, HistoryQuery{Fields: []string{"speed", "total_result"}, Step: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0.5, 2.5, 4.5}; !slices.Equal(down.Series["speed"], want) {
+		t.Errorf("averaged speed = %v, want %v", down.Series["speed"], want)
+	}
+	if want := []float64{1, 3, 5}; !slices.Equal(down.Series["total_result"], want) {
+		t.Errorf("cumulative total_result = %v, want %v", down.Series["total_result"], want)
+	}
+	if !down.Times[1].Equal(base.Add(2 * time.Second)) {
+		t.Errorf("bucket time = %v", down.Times[1])
+	}
+
+	if _, err := QuerySamples(samples, HistoryQuery{Fields: []string{"nope"}}); err == nil {
+		t.Error("unknown field accepted")
+	}
+	if _, err := QuerySamples(samples, HistoryQuery{Step: -time.Second}); err == nil {
+		t.Error("negative step accepted")
+	}
+
+	auto, err := QuerySamples(historyAt(base, 3*maxHistoryPoints), HistoryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auto.Times) > maxHistoryPoints+1 || auto.Step == 0 {
+		t.Errorf("auto step %g returned %d points", auto.Step, len(auto.Times))
+	}
+}
+
+func TestHistoryBounds(t *testing.T) {
+	base := testEpoch
+	h := NewHistory(5, 4*time.Second)
+	h.now = func() time.Time { return base.Add(9 * time.Second) }
+	for i := range 10 {
+		h.Add(frameAt(i, newStatus(10, uint64(i), 0, []int{1}, []int{i})))
+	}
+
+	// 容量保留最后 5 帧，保留时长再去掉早于 5s 的帧
+	got := h.Samples(time.Time{}, time.Time{})
+	if len(got) != 5 || got[0].TotalResult != 5 {
+		t.Errorf("samples = %+v", got)
+	}
+	got = h.Samples(base.Add(6*time.Second), base.Add(8*time.Second))
+	if len(got) != 3 || got[0].TotalResult != 6 || got[2].TotalResult != 8 {
+		t.Errorf("ranged samples = %+v", got)
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type IssueState string
+
+const (
+	IssueOpen     IssueState = "open"
+	IssueResolved IssueState = "resolved"
+)
+
+const maxResolvedIssues = 100
+
+type TrackedIssue struct {
+	HealthIssue
+	Core        string     `json:"core"`
+	State       IssueState `json:"state"`
+	StartedAt   time.Time  `json:"started_at"`
+	LastSeen    time.Time  `json:"last_seen"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Occurrences uint       `json:"occurrences"`
+}
+
+type CoreHealth string
+
+const (
+	HealthOK       CoreHealth = "ok"
+	HealthDegraded CoreHealth = "degraded"
+	HealthDown     CoreHealth = "down"
+)
+
+// HealthOf 根据最高严重程度得出健康状态，info 级别的问题不影响健康状态
+func HealthOf(severity Severity) CoreHealth {
+	switch severity {
+	case SeverityCritical:
+		return HealthDown
+	case SeverityWarning:
+		return HealthDegraded
+	}
+	return HealthOK
+}
+
+type HealthSummary struct {
+	Health     CoreHealth `json:"health"`
+	Severity   Severity   `json:"severity,omitempty"`
+	OpenIssues int        `json:"open_issues"`
+}
+
+// CoreEvent 指标计算过程中产生的事件，Web 服务以 Type 作为 WebSocket 消息类型推送
+type CoreEvent struct {
+	Type string
+	Data any
+}
+
+func IssueFingerprint(core string, issue HealthIssue) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d", issue.Type, core, issue.ThreadID)))
+	return hex.EncodeToString(sum[:8])
+}
+
+type IssueTracker struct {
+	core     string
+	lock     sync.Mutex
+	open     map[string]*TrackedIssue
+	resolved []TrackedIssue
+}
+
+func (t *IssueTracker) raise(issue HealthIssue, now time.Time) (CoreEvent, bool) {
+	issue.ID = IssueFingerprint(t.core, issue)
+	if tracked, ok := t.open[issue.ID]; ok {
+		tracked.HealthIssue = issue
+		tracked.LastSeen = now
+		tracked.Occurrences++
+		return CoreEvent{}, false
+	}
+	tracked := &TrackedIssue{
+		HealthIssue: issue,
+		Core:        t.core,
+		State:       IssueOpen,
+		StartedAt:   now,
+		LastSeen:    now,
+		Occurrences: 1,
+	}
+	t.open[issue.ID] = tracked
+	return CoreEvent{Type: "issue_opened", Data: *tracked}, true
+}
+
+func (t *IssueTracker) resolve(id string, now time.Time) CoreEvent {
+	tracked := t.open[id]
+	delete(t.open, id)
+	tracked.State = IssueResolved
+	tracked.ResolvedAt = &now
+	t.resolved = append(t.resolved, *tracked)
+	if len(t.resolved) > maxResolvedIssues {
+		t.resolved = slices.Delete(t.resolved, 0, len(t.resolved)-maxResolvedIssues)
+	}
+	return CoreEvent{Type: "issue_resolved", Data: *tracked}
+}
+
+// Observe 根据本帧评估出的问题更新状态，本帧未出现的问题视为已解决。
+// issues 中的 ID 会被填充为问题的标识。
+func (t *IssueTracker) Observe(issues HealthIssues, now time.Time) []CoreEvent {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var events []CoreEvent
+	seen := make(map[string]bool, len(issues))
+	for i := range issues {
+		issues[i].ID = IssueFingerprint(t.core, issues[i])
+		if seen[issues[i].ID] {
+			continue
+		}
+		seen[issues[i].ID] = true
+		if event, ok := t.raise(issues[i], now); ok {
+			events = append(events, event)
+		}
+	}
+	for _, id := range t.sortedOpen() {
+		if !seen[id] {
+			events = append(events, t.resolve(id, now))
+		}
+	}
+	return events
+}
+
+// Raise 记录规则引擎之外检测到的问题（如数据流停滞），下一帧未再出现时自动解决
+func (t *IssueTracker) Raise(issue HealthIssue, now time.Time) []CoreEvent {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if event, ok := t.raise(issue, now); ok {
+		return []CoreEvent{event}
+	}
+	return nil
+}
+
+func (t *IssueTracker) sortedOpen() []string {
+	ids := make([]string, 0, len(t.open))
+	for id := range t.open {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		if c := t.open[a].StartedAt.Compare(t.open[b].StartedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return ids
+}
+
+func (t *IssueTracker) Since(id string) (time.Time, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if tracked, ok := t.open[id]; ok {
+		return tracked.StartedAt, true
+	}
+	return time.Time{}, false
+}
+
+func (t *IssueTracker) Summary() HealthSummary {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var max Severity
+	for _, tracked := range t.open {
+		if tracked.Severity.Rank() > max.Rank() {
+			max = tracked.Severity
+		}
+	}
+	return HealthSummary{
+		Health:     HealthOf(max),
+		Severity:   max,
+		OpenIssues: len(t.open),
+	}
+}
+
+// 未解决的问题在前，按开始时间由新到旧排列；已解决的按解决时间由新到旧排列。
+func (t *IssueTracker) Issues(state IssueState, min Severity) []TrackedIssue {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	issues := make([]TrackedIssue, 0)
+	if state == "" || state == IssueOpen {
+		ids := t.sortedOpen()
+		for i := len(ids) - 1; i >= 0; i-- {
+			if t.open[ids[i]].Severity.AtLeast(min) {
+				issues = append(issues, *t.open[ids[i]])
+			}
+		}
+	}
+	if state == "" || state == IssueResolved {
+		for i := len(t.resolved) - 1; i >= 0; i-- {
+			if t.resolved[i].Severity.AtLeast(min) {
+				issues = append(issues, t.resolved[i])
+			}
+		}
+	}
+	return issues
+}
+
+func NewIssueTracker(core string) *IssueTracker {
+	return &IssueTracker{
+		core: core,
+		open: make(map[string]*TrackedIssue),
+	}
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// eventNames 将事件转为 "<事件类型>:<问题类型>" 便于比较
+func eventNames(events []CoreEvent) []string {
+	var names []string
+	for _, event := range events {
+		name := event.Type
+		if issue, ok := event.Data.(TrackedIssue); ok {
+			name += ":" + issue.Type
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func issue(issueType string, thread int, severity Severity) HealthIssue {
+	return HealthIssue{Type: issueType, ThreadID: thread, Severity: severity}
+}
+
+func TestIssueTrackerLifecycle(t *testing.T) {
+	base := testEpoch
+	steps := []struct {
+		name   string
+		issues HealthIssues
+		events []string
+		open   int
+	}{
+		{"opened", HealthIssues{issue("thread-blocking", 1, SeverityInfo), issue("low-thread-usage", 0, SeverityWarning)},
+			[]string{"issue_opened:thread-blocking", "issue_opened:low-thread-usage"}, 2},
+		{"still open", HealthIssues{issue("thread-blocking", 1, SeverityWarning), issue("low-thread-usage", 0, SeverityWarning)}, nil, 2},
+		{"duplicate in one frame", HealthIssues{issue("thread-blocking", 1, SeverityWarning), issue("thread-blocking", 1, SeverityWarning)},
+			[]string{"issue_resolved:low-thread-usage"}, 1},
+		{"other thread is another issue", HealthIssues{issue("thread-blocking", 1, SeverityWarning), issue("thread-blocking", 2, SeverityInfo)},
+			[]string{"issue_opened:thread-blocking"}, 2},
+		{"all resolved", nil, []string{"issue_resolved:thread-blocking", "issue_resolved:thread-blocking"}, 0},
+		{"reopened", HealthIssues{issue("low-thread-usage", 0, SeverityWarning)}, []string{"issue_opened:low-thread-usage"}, 1},
+	}
+
+	tracker := NewIssueTracker("a")
+	for i, step := range steps {
+		now := base.Add(time.Duration(i) * time.Second)
+		events := tracker.Observe(step.issues, now)
+		if got := eventNames(events); !slices.Equal(got, step.events) {
+			t.Errorf("%s: events %v, want %v", step.name, got, step.events)
+		}
+		if got := tracker.Summary().OpenIssues; got != step.open {
+			t.Errorf("%s: %d open issues, want %d", step.name, got, step.open)
+		}
+		for _, issue := range step.issues {
+			if issue.ID != IssueFingerprint("a", issue) {
+				t.Errorf("%s: issue ID %q not filled in", step.name, issue.ID)
+			}
+		}
+	}
+
+	// 持续期间只记录一次，开始时间与出现次数跨帧保留
+	resolved := tracker.Issues(IssueResolved, "")
+	if len(resolved) != 3 {
+		t.Fatalf("%d resolved issues, want 3", len(resolved))
+	}
+	blocking := resolved[1] // 由新到旧：线程 2、线程 1、low-thread-usage
+	if blocking.Type != "thread-blocking" || blocking.ThreadID != 1 || blocking.Occurrences != 4 ||
+		!blocking.StartedAt.Equal(base) || !blocking.ResolvedAt.Equal(base.Add(4*time.Second)) {
+		t.Errorf("thread 1 blocking issue = %+v", blocking)
+	}
+	reopened := tracker.Issues(IssueOpen, "")
+	if len(reopened) != 1 || !reopened[0].StartedAt.Equal(base.Add(5*time.Second)) || reopened[0].Occurrences != 1 {
+		t.Errorf("reopened issue = %+v", reopened)
+	}
+}
+
+func TestIssueFingerprint(t *testing.T) {
+	a := IssueFingerprint("a", issue("thread-blocking", 1, SeverityInfo))
+	tests := []struct {
+		name  string
+		core  string
+		issue HealthIssue
+		same  bool
+	}{
+		{"severity and text ignored", "a", HealthIssue{Type: "thread-blocking", ThreadID: 1, Severity: SeverityCritical, Description: "changed"}, true},
+		{"other thread", "a", issue("thread-blocking", 2, SeverityInfo), false},
+		{"other type", "a", issue("low-thread-usage", 1, SeverityInfo), false},
+		{"other core", "b", issue("thread-blocking", 1, SeverityInfo), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IssueFingerprint(tt.core, tt.issue); (got == a) != tt.same {
+				t.Errorf("fingerprint %s vs %s, want same %v", got, a, tt.same)
+			}
+		})
+	}
+}
+
+func TestIssuesFilter(t *testing.T) {
+	base := testEpoch
+	tracker := NewIssueTracker("a")
+	tracker.Observe(HealthIssues{issue("x", 1, SeverityInfo), issue("x", 2, SeverityCritical)}, base)
+	tracker.Observe(HealthIssues{issue("x", 1, SeverityInfo), issue("x", 3, SeverityWarning)}, base.Add(time.Second))
+
+	threads := func(issues []TrackedIssue) []string {
+		var ids []string
+		for _, issue := range issues {
+			ids = append(ids, fmt.Sprintf("%s:%d", issue.State, issue.ThreadID))
+		}
+		return ids
+	}
+	tests := []struct {
+		state IssueState
+		min   Severity
+		want  []string
+	}{
+		{"", "", []string{"open:3", "open:1", "resolved:2"}},
+		{IssueOpen, "", []string{"open:3", "open:1"}},
+		{IssueResolved, "", []string{"resolved:2"}},
+		{"", SeverityWarning, []string{"open:3", "resolved:2"}},
+		{IssueOpen, SeverityCritical, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%s", tt.state, tt.min), func(t *testing.T) {
+			if got := threads(tracker.Issues(tt.state, tt.min)); !slices.Equal(got, tt.want) {
+				t.Errorf("Issues(%q, %q) = %v, want %v", tt.state, tt.min, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvedIssuesBounded(t *testing.T) {
+	base := testEpoch
+	tracker := NewIssueTracker("a")
+	for i := range maxResolvedIssues + 10 {
+		tracker.Observe(HealthIssues{issue("x", i, SeverityInfo)}, base.Add(time.Duration(i)*time.Second))
+	}
+	resolved := tracker.Issues(IssueResolved, "")
+	if len(resolved) != maxResolvedIssues || resolved[0].ThreadID != maxResolvedIssues+8 {
+		t.Errorf("%d resolved issues, newest thread %d", len(resolved), resolved[0].ThreadID)
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type LogRecord struct {
+	Time     string         `json:"time"`
+	Level    string         `json:"level"`
+	Message  string         `json:"message"`
+	ThreadID *int           `json:"thread_id,omitempty"`
+	Fields   map[string]any `json:"-"`
+}
+
+// ParseLog 解析 JSON 格式的日志行，无法解析时整行作为消息，级别为空
+func ParseLog(line string) LogRecord {
+	var record LogRecord
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return LogRecord{Message: line}
+	}
+	if err := json.Unmarshal([]byte(line), &record.Fields); err == nil {
+		for _, key := range []string{"time", "level", "message", "thread_id"} {
+			delete(record.Fields, key)
+		}
+	}
+	return record
+}
+
+func (lr LogRecord) IsError() bool {
+	switch strings.ToUpper(lr.Level) {
+	case "ERROR", "FATAL", "PANIC":
+		return true
+	}
+	return false
+}
@@ -6,49 +6,106 @@ import (
 	"time"
 
 	"github.com/B9O2/monitors/monitor"
-	"github.com/B9O2/mtmonitor/runtime"
 	"github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
 )
 
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+func (s Severity) AtLeast(min Severity) bool {
+	return s.Rank() >= min.Rank()
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	if s == "" {
+		return SeverityInfo, nil
+	}
+	severity := Severity(s)
+	if severity.Rank() == 0 {
+		return "", fmt.Errorf("unknown severity %q, expected info, warning or critical", s)
+	}
+	return severity, nil
+}
+
 type HealthIssues []HealthIssue
 
-func (hi *HealthIssues) ThreadBlockingIssue(threadID int, description string) {
+func (hi *HealthIssues) ThreadBlockingIssue(threadID int, description string, severity Severity) {
 	*hi = append(*hi, HealthIssue{
 		Type:        "thread-blocking",
 		Title:       "线程阻塞",
 		Description: description,
 		ThreadID:    threadID,
-		Alert:       false,
+		Severity:    severity,
+		Alert:       severity == SeverityCritical,
 	})
 
 }
 
-func (hi *HealthIssues) Append(title string, description string, alert bool) {
+func (hi *HealthIssues) Append(issueType string, title string, description string, severity Severity) {
 	*hi = append(*hi, HealthIssue{
-		Type:        "core-health-issue",
+		Type:        issueType,
 		Title:       title,
 		Description: description,
 		ThreadID:    -1,
-		Alert:       alert,
+		Severity:    severity,
+		Alert:       severity == SeverityCritical,
 	})
 }
 
 type HealthIssue struct {
-	Type        string `json:"type"` //
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Alert       bool   `json:"alert"`
-	ThreadID    int    `json:"thread_id"` // 相关线程ID
+	ID          string   `json:"id"`   // 稳定标识，由 IssueTracker 填充
+	Type        string   `json:"type"` //
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Severity    Severity `json:"severity"`
+	Alert       bool     `json:"alert"`     // 兼容旧界面，等同于 severity 为 critical
+	ThreadID    int      `json:"thread_id"` // 相关线程ID
 }
 
 type Metrics struct {
 	*monitor.Status
+	Time                time.Time    `json:"time"`
 	Speed               float64      `json:"speed"`
+	SpeedEWMA           float64      `json:"speed_ewma"`
+	SpeedStats          SpeedStats   `json:"speed_stats"`
+	Pending             uint64       `json:"pending"`
+	ProgressPct         float64      `json:"progress_pct"`
+	ETA                 *float64     `json:"eta"`       // 按平滑速度估算的剩余秒数，无法估算时为 null
+	Completed           bool         `json:"completed"` // 所有任务均已完成且线程全部空闲
+	ErrorLogRate        float64      `json:"error_log_rate"`
+	RetryRate           float64      `json:"retry_rate"`
+	RetryRatio          float64      `json:"retry_ratio"`
+	RetryQueueTrend     float64      `json:"retry_queue_trend"`
+	RetryQueueGrowing   uint         `json:"retry_queue_growing"`
 	Idle                uint64       `json:"idle"`
 	Working             uint64       `json:"working"`
 	ThreadsWorkingTimes []uint       `json:"threads_working_times"`
+	ThreadsBusySince    []*time.Time `json:"threads_busy_since"` // 线程开始处理当前任务的时间，空闲时为 null
+	ThreadsRate         []float64    `json:"threads_rate"`
+	ThreadsShare        []float64    `json:"threads_share"`
+	Imbalance           float64      `json:"imbalance"` // 线程间完成任务数的基尼系数，0 为完全均衡
+	HotThreads          []int        `json:"hot_threads"`
+	ColdThreads         []int        `json:"cold_threads"`
 	HealthIssues        HealthIssues `json:"health_issues"`
+	CoreEvents          []CoreEvent  `json:"-"` // 本帧产生的事件，由 Web 服务单独推送
 }
 
 func (m *Metrics) ThreadsCountChart() *widgets.BarChart {
@@ -72,10 +129,14 @@ func (m *Metrics) StatsTable() *widgets.Table {
 		{"Task", fmt.Sprint(m.Status.TotalTask)},
 		{"Retry", fmt.Sprint(m.Status.TotalRetry)},
 		{"Max Retry Queue", fmt.Sprint(m.Status.RetrySize)},
+		{"Retry Rate", fmt.Sprintf("%.2f/s (trend %+.2f/s)", m.RetryRate, m.RetryQueueTrend)},
 		{"Result", fmt.Sprint(m.Status.TotalResult)},
-		{"Usage Rate", fmt.Sprintf("%.2f%%", float64(m.Working)/float64(len(m.ThreadsDetail.ThreadsStatus))*100)},
-		{"Working", fmt.Sprintf("%d/%d", m.Working, len(m.ThreadsDetail.ThreadsStatus))},
+		{"Usage Rate", fmt.Sprintf("%.2f%%", m.UsageRate()*100)},
+		{"Working", fmt.Sprintf("%d/%d", m.Working, m.PoolSize())},
 		{"Idle", fmt.Sprint(m.Idle)},
+		{"Pending", fmt.Sprint(m.Pending)},
+		{"Progress", fmt.Sprintf("%.2f%%", m.ProgressPct)},
+		{"ETA", m.ETAString()},
 	}
 
 	if m.Speed != 0 {
@@ -83,6 +144,11 @@ func (m *Metrics) StatsTable() *widgets.Table {
 	} else {
 		table.Rows = append(table.Rows, []string{"Speed", "------"})
 	}
+	table.Rows = append(table.Rows,
+		[]string{"Speed 1m/5m/15m", fmt.Sprintf("%.2f/%.2f/%.2f", m.SpeedStats.Avg1m, m.SpeedStats.Avg5m, m.SpeedStats.Avg15m)},
+		[]string{"Speed p50/p95", fmt.Sprintf("%.2f/%.2f", m.SpeedStats.P50, m.SpeedStats.P95)},
+		[]string{"Peak Speed", fmt.Sprintf("%.2f/s", m.SpeedStats.Peak)},
+	)
 
 	table.TextStyle = termui.NewStyle(termui.ColorWhite)
 	table.RowSeparator = false
@@ -98,9 +164,10 @@ func (m *Metrics) String() string {
 	builder.WriteString(fmt.Sprint(" Retry: ", m.Status.TotalRetry))
 	builder.WriteString(fmt.Sprint(" Max Retry Queue: ", m.Status.RetrySize))
 	builder.WriteString(fmt.Sprint(" Result: ", m.Status.TotalResult, "\n"))
-	builder.WriteString(fmt.Sprintf(" Usage Rate: %.2f%%", float64(m.Working)/float64(len(m.ThreadsDetail.ThreadsStatus))*100))
-	builder.WriteString(fmt.Sprintf(" Working: %d/%d", m.Working, len(m.ThreadsDetail.ThreadsStatus)))
+	builder.WriteString(fmt.Sprintf(" Usage Rate: %.2f%%", m.UsageRate()*100))
+	builder.WriteString(fmt.Sprintf(" Working: %d/%d", m.Working, m.PoolSize()))
 	builder.WriteString(fmt.Sprintf(" Idle: %d\n", m.Idle))
+	builder.WriteString(fmt.Sprintf(" Pending: %d Progress: %.2f%% ETA: %s\n", m.Pending, m.ProgressPct, m.ETAString()))
 	if m.Speed != 0 {
 		builder.WriteString(fmt.Sprintf(" Speed: %f/s", m.Speed))
 	} else {
@@ -109,29 +176,40 @@ func (m *Metrics) String() string {
 	return builder.String()
 }
 
-func NewMetrics(status *monitor.Status, lastMetrics *Metrics, interval time.Duration, healthCheckConfig runtime.HealthCheckConfig) *Metrics {
-	var speed float64
-	var healthIssues HealthIssues
+func NewMetrics(status *monitor.Status, lastMetrics *Metrics, interval time.Duration) *Metrics {
+	return newMetricsAt(status, lastMetrics, interval, time.Now())
+}
+
+// newMetricsAt 同 NewMetrics，now 为本帧的时间
+func newMetricsAt(status *monitor.Status, lastMetrics *Metrics, interval time.Duration, now time.Time) *Metrics {
+	var speed, retryRate float64
+	var retryGrowing uint
 
-	threadsWorkingTimes := make([]uint, len(status.ThreadsDetail.ThreadsStatus))
 	if lastMetrics != nil && lastMetrics.Status != nil {
-		speed = float64((status.TotalResult - lastMetrics.TotalResult)) / interval.Seconds()
-
-		for tid := range status.ThreadsDetail.ThreadsStatus {
-			if status.ThreadsDetail.ThreadsStatus[tid] == 1 && status.ThreadsDetail.ThreadsCount[tid] == lastMetrics.ThreadsDetail.ThreadsCount[tid] {
-				threadsWorkingTimes[tid] += 1
-				if threadsWorkingTimes[tid] >= healthCheckConfig.MaxWorkingIntervalTimes {
-					healthIssues.ThreadBlockingIssue(
-						tid,
-						fmt.Sprintf("Thread %d has been working for %d intervals, which exceeds the maximum allowed of %d intervals.", tid, threadsWorkingTimes[tid], healthCheckConfig.MaxWorkingIntervalTimes),
-					)
-				}
-			} else {
-				threadsWorkingTimes[tid] = 0
-			}
+		delta := status.TotalResult - lastMetrics.TotalResult
+		if status.TotalResult < lastMetrics.TotalResult {
+			// 计数器被重置（核心重启），重启后的结果数即为本周期的增量
+			delta = status.TotalResult
+		}
+		speed = float64(delta) / interval.Seconds()
+
+		retryDelta := status.TotalRetry - lastMetrics.TotalRetry
+		if status.TotalRetry < lastMetrics.TotalRetry {
+			retryDelta = status.TotalRetry
+		}
+		retryRate = float64(retryDelta) / interval.Seconds()
+
+		// 队列连续增长的周期数，不变、缩小或清空时归零
+		if status.RetrySize > lastMetrics.RetrySize {
+			retryGrowing = lastMetrics.RetryQueueGrowing + 1
 		}
 	}
 
+	var retryRatio float64
+	if status.TotalResult > 0 {
+		retryRatio = float64(status.TotalRetry) / float64(status.TotalResult)
+	}
+
 	idle := uint64(0)
 	working := uint64(0)
 
@@ -143,32 +221,74 @@ func NewMetrics(status *monitor.Status, lastMetrics *Metrics, interval time.Dura
 		}
 	}
 
-	if working == 0 {
-		healthIssues.Append(
-			"No Threads Working",
-			"All threads are idle, which may indicate a lack of tasks or an issue with task distribution.",
-			true,
-		)
-	} else {
-		usage := float32(working) / float32(len(status.ThreadsDetail.ThreadsStatus))
-		//fmt.Printf("Thread Usage: %.2f%%\n", usage*100)
-		if usage < healthCheckConfig.MinUsageRate {
-			healthIssues.Append(
-				"Low Thread Usage",
-				fmt.Sprintf("Only %.2f%% of threads are working, which is below the minimum usage rate of %.2f%%.", usage*100, healthCheckConfig.MinUsageRate*100),
-				false,
-			)
-		}
+	var pending uint64
+	var progress float64
+	if status.TotalTask > status.TotalResult {
+		pending = uint64(status.TotalTask - status.TotalResult)
+	}
+	if status.TotalTask > 0 {
+		progress = min(float64(status.TotalResult)/float64(status.TotalTask)*100, 100)
 	}
 
 	metrics := &Metrics{
 		Status:              status,
+		Time:                now,
 		Speed:               speed,
+		RetryRate:           retryRate,
+		RetryRatio:          retryRatio,
+		RetryQueueGrowing:   retryGrowing,
+		Pending:             pending,
+		ProgressPct:         progress,
+		Completed:           status.TotalTask > 0 && pending == 0 && working == 0,
 		Idle:                idle,
 		Working:             working,
-		ThreadsWorkingTimes: threadsWorkingTimes,
+		ThreadsWorkingTimes: make([]uint, len(status.ThreadsDetail.ThreadsStatus)),
 
-		HealthIssues: healthIssues,
+		HealthIssues: HealthIssues{},
 	}
 	return metrics
 }
+
+// retryQueueTrend 用最小二乘法拟合历史帧中重试队列长度随时间的变化，返回每秒的增量
+func retryQueueTrend(history []*Metrics) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+	origin := history[0].Time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, m := range history {
+		x := m.Time.Sub(origin).Seconds()
+		y := float64(m.RetrySize)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(history))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+func (m *Metrics) ETAString() string {
+	switch {
+	case m.Completed || (m.ETA != nil && *m.ETA == 0):
+		return "done"
+	case m.ETA == nil:
+		return "------"
+	}
+	return roundDuration(time.Duration(*m.ETA * float64(time.Second))).String()
+}
+
+func (m *Metrics) PoolSize() int {
+	return len(m.ThreadsDetail.ThreadsStatus)
+}
+
+func (m *Metrics) UsageRate() float64 {
+	if m.PoolSize() == 0 {
+		return 0
+	}
+	return float64(m.Working) / float64(m.PoolSize())
+}
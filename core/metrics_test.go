@@ -0,0 +1,78 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Severity
+		err  bool
+	}{
+		{"", SeverityInfo, false},
+		{"info", SeverityInfo, false},
+		{"warning", SeverityWarning, false},
+		{"critical", SeverityCritical, false},
+		{"fatal", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSeverity(tt.in)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("ParseSeverity(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestSeverityOrder(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		min      Severity
+		atLeast  bool
+		health   CoreHealth
+	}{
+		{SeverityInfo, "", true, HealthOK},
+		{SeverityInfo, SeverityWarning, false, HealthOK},
+		{SeverityWarning, SeverityWarning, true, HealthDegraded},
+		{SeverityCritical, SeverityWarning, true, HealthDown},
+		{"", SeverityInfo, false, HealthOK},
+	}
+	for _, tt := range tests {
+		if got := tt.severity.AtLeast(tt.min); got != tt.atLeast {
+			t.Errorf("%q.AtLeast(%q) = %v, want %v", tt.severity, tt.min, got, tt.atLeast)
+		}
+		if got := HealthOf(tt.severity); got != tt.health {
+			t.Errorf("HealthOf(%q) = %q, want %q", tt.severity, got, tt.health)
+		}
+	}
+}
+
+// 线程池为空时所有派生指标都有定义
+func TestEmptyPool(t *testing.T) {
+	c, clock := newTestCollector(t, time.Second)
+	c.Update(newStatus(10, 2, 0, nil, nil))
+	clock.advance(time.Second)
+	m := c.Update(newStatus(10, 4, 0, nil, nil))
+
+	if m.PoolSize() != 0 || m.UsageRate() != 0 || m.Working != 0 || m.Idle != 0 {
+		t.Errorf("pool %d, usage %g, working %d, idle %d", m.PoolSize(), m.UsageRate(), m.Working, m.Idle)
+	}
+	if len(m.ThreadsBusySince) != 0 || len(m.ThreadsWorkingTimes) != 0 {
+		t.Errorf("per-thread stats %v %v", m.ThreadsBusySince, m.ThreadsWorkingTimes)
+	}
+	rendered := []string{m.String()}
+	for _, row := range m.StatsTable().Rows {
+		rendered = append(rendered, strings.Join(row, " "))
+	}
+	for _, text := range rendered {
+		if strings.Contains(text, "NaN") || strings.Contains(text, "Inf") {
+			t.Errorf("rendered %q", text)
+		}
+	}
+	if data, err := json.Marshal(m); err != nil {
+		t.Errorf("json.Marshal() error = %v, metrics %s", err, data)
+	}
+}
@@ -0,0 +1,364 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+	"github.com/B9O2/mtmonitor/runtime"
+	collogpb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	logpb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpItem 队列中的一帧指标或一次事件中的日志
+type otlpItem struct {
+	core     string
+	tags     map[string]string
+	metrics  *Metrics
+	start    time.Time // 指标计数器的开始时间
+	logs     []string
+	received time.Time
+}
+
+type otlpClient interface {
+	exportMetrics(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error
+	exportLogs(ctx context.Context, req *collogpb.ExportLogsServiceRequest) error
+	close() error
+}
+
+type grpcOTLPClient struct {
+	conn    *grpc.ClientConn
+	metrics colmetricpb.MetricsServiceClient
+	logs    collogpb.LogsServiceClient
+	headers metadata.MD
+}
+
+func (c *grpcOTLPClient) exportMetrics(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error {
+	_, err := c.metrics.Export(metadata.NewOutgoingContext(ctx, c.headers), req)
+	return err
+}
+
+func (c *grpcOTLPClient) exportLogs(ctx context.Context, req *collogpb.ExportLogsServiceRequest) error {
+	_, err := c.logs.Export(metadata.NewOutgoingContext(ctx, c.headers), req)
+	return err
+}
+
+func (c *grpcOTLPClient) close() error {
+	return c.conn.Close()
+}
+
+// httpStatusError 收集器返回的非 2xx 响应
+type httpStatusError struct {
+	code int
+	body string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("collector responded %d: %s", e.code, e.body)
+}
+
+type httpOTLPClient struct {
+	client   *http.Client
+	endpoint string
+	headers  map[string]string
+}
+
+func (c *httpOTLPClient) post(ctx context.Context, path string, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &httpStatusError{code: resp.StatusCode, body: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
+func (c *httpOTLPClient) exportMetrics(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) error {
+	return c.post(ctx, "/v1/metrics", req)
+}
+
+func (c *httpOTLPClient) exportLogs(ctx context.Context, req *collogpb.ExportLogsServiceRequest) error {
+	return c.post(ctx, "/v1/logs", req)
+}
+
+func (c *httpOTLPClient) close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// retryable 按 OTLP 规范判断导出失败后是否可以重试，其余错误直接丢弃这一批数据
+func retryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.code {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		switch s.Code() {
+		case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+			codes.Unavailable, codes.DataLoss, codes.ResourceExhausted:
+			return true
+		}
+		return false
+	}
+	// 网络错误
+	return true
+}
+
+// OTLPExporter 将指标与日志按批发送到 OTLP 收集器。
+// 数据先进入有界队列，队列满时丢弃新的数据，不会阻塞数据流。
+type OTLPExporter struct {
+	client        otlpClient
+	queue         chan otlpItem
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	retry         runtime.ReconnectPolicy
+	dropped       atomic.Uint64
+	lock          sync.Mutex
+	starts        map[string]time.Time // 各核心计数器本次运行的开始时间
+	done          chan struct{}        // Run 返回时关闭
+}
+
+func (e *OTLPExporter) enqueue(item otlpItem) {
+	select {
+	case e.queue <- item:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *OTLPExporter) ExportMetrics(core string, tags map[string]string, m *Metrics) {
+	e.enqueue(otlpItem{core: core, tags: tags, metrics: m, start: e.startOf(core, m)})
+}
+
+func (e *OTLPExporter) ExportLogs(core string, tags map[string]string, events *monitor.Events) {
+	if len(events.Logs) == 0 {
+		return
+	}
+	e.enqueue(otlpItem{core: core, tags: tags, logs: events.Logs, received: time.Now()})
+}
+
+// Dropped 返回因队列已满或导出失败而丢弃的帧数与日志事件数
+func (e *OTLPExporter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// startOf 核心重启后计数器从新的开始时间累计
+func (e *OTLPExporter) startOf(core string, m *Metrics) time.Time {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	start, ok := e.starts[core]
+	restarted := slices.ContainsFunc(m.CoreEvents, func(event CoreEvent) bool { return event.Type == "core_restarted" })
+	if !ok || restarted {
+		start = m.Time
+		e.starts[core] = start
+	}
+	return start
+}
+
+// Forget 清除核心计数器的开始时间，在核心停止、不再导出指标后调用
+func (e *OTLPExporter) Forget(core string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.starts, core)
+}
+
+// send 发送一次请求，可恢复的错误按重试策略退避后重试，最多尝试 retry.MaxAttempts 次
+func (e *OTLPExporter) send(ctx context.Context, export func(ctx context.Context) error) error {
+	for attempt := uint(1); ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		err := export(callCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) || attempt >= e.retry.MaxAttempts {
+			return err
+		}
+		timer := time.NewTimer(e.retry.Delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+func (e *OTLPExporter) export(ctx context.Context, batch []otlpItem) error {
+	metrics := newOTLPMetrics()
+	var records []*logpb.LogRecord
+	var metricItems, logItems uint64
+	for _, item := range batch {
+		if item.metrics != nil {
+			metrics.add(item.core, item.tags, item.start, item.metrics)
+			metricItems++
+			continue
+		}
+		for _, line := range item.logs {
+			records = append(records, otlpLogRecord(item.core, item.tags, item.received, ParseLog(line)))
+		}
+		logItems++
+	}
+
+	var errs []error
+	if metricItems > 0 {
+		req := &colmetricpb.ExportMetricsServiceRequest{ResourceMetrics: metrics.resourceMetrics()}
+		if err := e.send(ctx, func(ctx context.Context) error { return e.client.exportMetrics(ctx, req) }); err != nil {
+			e.dropped.Add(metricItems)
+			errs = append(errs, fmt.Errorf("export metrics: %w", err))
+		}
+	}
+	if logItems > 0 {
+		req := &collogpb.ExportLogsServiceRequest{ResourceLogs: resourceLogs(records)}
+		if err := e.send(ctx, func(ctx context.Context) error { return e.client.exportLogs(ctx, req) }); err != nil {
+			e.dropped.Add(logItems)
+			errs = append(errs, fmt.Errorf("export logs: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run 按批次大小或发送周期导出队列中的数据，直到 ctx 取消；
+// 取消后在一个超时时间内尽量发送剩余的数据。每次导出失败或有数据被丢弃时调用 onError。
+// 每个导出器只能运行一次。
+func (e *OTLPExporter) Run(ctx context.Context, onError func(err error)) {
+	defer close(e.done)
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	var batch []otlpItem
+	var reported uint64
+	flush := func(ctx context.Context) {
+		if len(batch) > 0 {
+			if err := e.export(ctx, batch); err != nil {
+				onError(err)
+			}
+			batch = nil
+		}
+		if dropped := e.dropped.Load(); dropped > reported {
+			onError(fmt.Errorf("%d items dropped in total", dropped))
+			reported = dropped
+		}
+	}
+
+	for {
+		select {
+		case item := <-e.queue:
+			batch = append(batch, item)
+			// ctx 已取消时不能再用它发送，留给最后一次发送
+			if len(batch) >= e.batchSize && ctx.Err() == nil {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			if ctx.Err() == nil {
+				flush(ctx)
+			}
+		case <-ctx.Done():
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			final, cancel := context.WithTimeout(context.Background(), e.timeout)
+			flush(final)
+			cancel()
+			e.client.close()
+			return
+		}
+	}
+}
+
+// Wait 等待 Run 在 ctx 取消后发送完剩余的数据并返回，最多等待一个超时时间
+func (e *OTLPExporter) Wait() error {
+	select {
+	case <-e.done:
+		return nil
+	case <-time.After(e.timeout):
+		return fmt.Errorf("exporter did not stop within %s", e.timeout)
+	}
+}
+
+// NewOTLPExporter 根据配置创建导出器，未配置 endpoint 时返回 nil
+func NewOTLPExporter(oc runtime.OTLPConfig) (*OTLPExporter, error) {
+	if oc.Endpoint == "" {
+		return nil, nil
+	}
+	policy, err := oc.RetryPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	var client otlpClient
+	switch oc.ProtocolOrDefault() {
+	case runtime.OTLPProtocolGRPC:
+		creds := credentials.NewTLS(&tls.Config{})
+		if oc.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(oc.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		client = &grpcOTLPClient{
+			conn:    conn,
+			metrics: colmetricpb.NewMetricsServiceClient(conn),
+			logs:    collogpb.NewLogsServiceClient(conn),
+			headers: metadata.New(oc.Headers),
+		}
+	case runtime.OTLPProtocolHTTP:
+		client = &httpOTLPClient{
+			client:   &http.Client{},
+			endpoint: strings.TrimSuffix(oc.Endpoint, "/"),
+			headers:  oc.Headers,
+		}
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", oc.Protocol)
+	}
+
+	return &OTLPExporter{
+		client:        client,
+		queue:         make(chan otlpItem, oc.QueueSizeOrDefault()),
+		batchSize:     oc.BatchSizeOrDefault(),
+		flushInterval: oc.FlushIntervalDuration(),
+		timeout:       oc.TimeoutDuration(),
+		retry:         policy,
+		starts:        make(map[string]time.Time),
+		done:          make(chan struct{}),
+	}, nil
+}
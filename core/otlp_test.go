@@ -0,0 +1,342 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+	"github.com/B9O2/mtmonitor/runtime"
+	collogpb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector 记录收到的导出请求，前 failures 次请求返回 fail
+type fakeCollector struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	collogpb.UnimplementedLogsServiceServer
+	lock     sync.Mutex
+	failures int
+	fail     error
+	metrics  []*colmetricpb.ExportMetricsServiceRequest
+	logs     []*collogpb.ExportLogsServiceRequest
+	headers  []string
+}
+
+func (fc *fakeCollector) accept(ctx context.Context) error {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		fc.headers = append(fc.headers, md.Get("authorization")...)
+	}
+	if fc.failures > 0 {
+		fc.failures--
+		return fc.fail
+	}
+	return nil
+}
+
+func (fc *fakeCollector) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	if err := fc.accept(ctx); err != nil {
+		return nil, err
+	}
+	fc.lock.Lock()
+	fc.metrics = append(fc.metrics, req)
+	fc.lock.Unlock()
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+// logsServer 与 MetricsServiceServer 的 Export 方法同名，单独实现
+type logsServer struct {
+	collogpb.UnimplementedLogsServiceServer
+	fc *fakeCollector
+}
+
+func (ls logsServer) Export(ctx context.Context, req *collogpb.ExportLogsServiceRequest) (*collogpb.ExportLogsServiceResponse, error) {
+	if err := ls.fc.accept(ctx); err != nil {
+		return nil, err
+	}
+	ls.fc.lock.Lock()
+	ls.fc.logs = append(ls.fc.logs, req)
+	ls.fc.lock.Unlock()
+	return &collogpb.ExportLogsServiceResponse{}, nil
+}
+
+func newGRPCCollector(t *testing.T) (*fakeCollector, string) {
+	t.Helper()
+	fc := &fakeCollector{}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	colmetricpb.RegisterMetricsServiceServer(server, fc)
+	collogpb.RegisterLogsServiceServer(server, logsServer{fc: fc})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return fc, lis.Addr().String()
+}
+
+func newHTTPCollector(t *testing.T) (*fakeCollector, string) {
+	t.Helper()
+	fc := &fakeCollector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := metadata.NewIncomingContext(r.Context(), metadata.Pairs("authorization", r.Header.Get("Authorization")))
+		if err := fc.accept(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		fc.lock.Lock()
+		switch r.URL.Path {
+		case "/v1/metrics":
+			req := &colmetricpb.ExportMetricsServiceRequest{}
+			proto.Unmarshal(body, req)
+			fc.metrics = append(fc.metrics, req)
+		case "/v1/logs":
+			req := &collogpb.ExportLogsServiceRequest{}
+			proto.Unmarshal(body, req)
+			fc.logs = append(fc.logs, req)
+		}
+		fc.lock.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return fc, server.URL
+}
+
+func findMetric(req *colmetricpb.ExportMetricsServiceRequest, name string) *metricpb.Metric {
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestOTLPExporter(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		collect  func(t *testing.T) (*fakeCollector, string)
+	}{
+		{"grpc", runtime.OTLPProtocolGRPC, newGRPCCollector},
+		{"http", runtime.OTLPProtocolHTTP, newHTTPCollector},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, endpoint := tt.collect(t)
+			// 第一次请求失败，重试后成功
+			fc.failures = 1
+			fc.fail = status.Error(codes.Unavailable, "starting")
+			e, err := NewOTLPExporter(runtime.OTLPConfig{
+				Endpoint:  endpoint,
+				Protocol:  tt.protocol,
+				Insecure:  tt.protocol == runtime.OTLPProtocolGRPC,
+				Headers:   map[string]string{"authorization": "Bearer t"},
+				BatchSize: 3,
+				Retry:     runtime.ReconnectConfig{InitialDelay: "1ms", MaxDelay: "1ms"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now()
+			tags := map[string]string{"env": "prod"}
+			m := &Metrics{Status: newStatus(100, 40, 3, []int{1, 0}, []int{30, 10}), Time: now, Speed: 4, Working: 1, Idle: 1}
+			e.ExportMetrics("a", tags, m)
+			e.ExportMetrics("a", tags, &Metrics{Status: newStatus(100, 50, 3, []int{1, 1}, []int{35, 15}), Time: now.Add(time.Second), Working: 2})
+			e.ExportLogs("a", tags, &monitor.Events{Logs: []string{`{"level":"ERROR","message":"boom","thread_id":1,"task":"t1"}`, "plain line"}})
+
+			// ctx 已取消时 Run 发送队列中剩余的数据后返回
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			e.Run(ctx, func(err error) { t.Errorf("export error: %v", err) })
+
+			fc.lock.Lock()
+			defer fc.lock.Unlock()
+			if len(fc.metrics) != 1 || len(fc.logs) != 1 {
+				t.Fatalf("%d metrics and %d logs requests", len(fc.metrics), len(fc.logs))
+			}
+			results := findMetric(fc.metrics[0], "mtmonitor.core.results").GetSum()
+			if !results.IsMonotonic || len(results.DataPoints) != 2 || results.DataPoints[1].GetAsInt() != 50 {
+				t.Errorf("results = %v", results)
+			}
+			if start := results.DataPoints[1].StartTimeUnixNano; start != uint64(now.UnixNano()) {
+				t.Errorf("start time %d, want first frame %d", start, now.UnixNano())
+			}
+			attrs := results.DataPoints[0].Attributes
+			if len(attrs) != 2 || attrs[0].Value.GetStringValue() != "a" || attrs[1].Key != "env" {
+				t.Errorf("attributes = %v", attrs)
+			}
+			if threads := findMetric(fc.metrics[0], "mtmonitor.core.threads").GetGauge(); len(threads.DataPoints) != 4 {
+				t.Errorf("%d thread data points, want 4", len(threads.DataPoints))
+			}
+
+			records := fc.logs[0].ResourceLogs[0].ScopeLogs[0].LogRecords
+			if len(records) != 2 || records[0].Body.GetStringValue() != "boom" || records[0].SeverityText != "ERROR" {
+				t.Fatalf("log records = %v", records)
+			}
+			got := make(map[string]string)
+			for _, kv := range records[0].Attributes {
+				got[kv.Key] = kv.Value.String()
+			}
+			for _, key := range []string{"core", "env", "level", "thread_id", "task"} {
+				if _, ok := got[key]; !ok {
+					t.Errorf("log attribute %s missing in %v", key, got)
+				}
+			}
+			if records[1].Body.GetStringValue() != "plain line" || records[1].TimeUnixNano == 0 {
+				t.Errorf("plain log record = %v", records[1])
+			}
+			if len(fc.headers) == 0 || fc.headers[0] != "Bearer t" {
+				t.Errorf("headers = %v", fc.headers)
+			}
+		})
+	}
+}
+
+func TestOTLPExporterDrops(t *testing.T) {
+	fc, endpoint := newGRPCCollector(t)
+	fc.failures = 1
+	fc.fail = status.Error(codes.InvalidArgument, "bad request")
+	e, err := NewOTLPExporter(runtime.OTLPConfig{Endpoint: endpoint, Insecure: true, QueueSize: 2, BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未运行时队列只能容纳 2 项
+	for range 3 {
+		e.ExportLogs("a", nil, &monitor.Events{Logs: []string{"x"}})
+	}
+	if e.Dropped() != 1 {
+		t.Fatalf("dropped %d, want 1", e.Dropped())
+	}
+
+	// 不可重试的错误直接丢弃整批数据
+	var errs []error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.Run(ctx, func(err error) { errs = append(errs, err) })
+	if e.Dropped() != 3 || len(errs) == 0 {
+		t.Errorf("dropped %d with %d errors after a rejected export", e.Dropped(), len(errs))
+	}
+}
+
+// 未设置 max_attempts 时重试有上限，失败的一批数据被丢弃
+func TestOTLPExporterRetryLimit(t *testing.T) {
+	fc, endpoint := newGRPCCollector(t)
+	fc.failures = 100
+	fc.fail = status.Error(codes.Unavailable, "down")
+	e, err := NewOTLPExporter(runtime.OTLPConfig{
+		Endpoint: endpoint,
+		Insecure: true,
+		Retry:    runtime.ReconnectConfig{InitialDelay: "1ms", MaxDelay: "1ms"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ExportLogs("a", nil, &monitor.Events{Logs: []string{"x"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var errs []error
+	e.Run(ctx, func(err error) { errs = append(errs, err) })
+
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	if attempts := 100 - fc.failures; attempts != runtime.DefaultOTLPRetryAttempts {
+		t.Errorf("%d attempts, want %d", attempts, runtime.DefaultOTLPRetryAttempts)
+	}
+	if e.Dropped() != 1 || len(errs) == 0 {
+		t.Errorf("dropped %d with %d errors after retries ran out", e.Dropped(), len(errs))
+	}
+}
+
+// 日志字段与核心名、标签或日志自身属性重名时加前缀，属性键不重复
+func TestOTLPLogRecordAttributes(t *testing.T) {
+	tags := map[string]string{"env": "prod", "level": "tag"}
+	record := ParseLog(`{"level":"ERROR","message":"boom","thread_id":1,"core":"x","env":"y","log.env":"z","task":"t"}`)
+	attrs := otlpLogRecord("a", tags, time.Now(), record).Attributes
+
+	got := make(map[string]string)
+	for _, kv := range attrs {
+		if _, ok := got[kv.Key]; ok {
+			t.Errorf("duplicate attribute %s", kv.Key)
+		}
+		got[kv.Key] = kv.Value.GetStringValue()
+		if kv.Key == "thread_id" {
+			got[kv.Key] = strconv.FormatInt(kv.Value.GetIntValue(), 10)
+		}
+	}
+	// 重名的 env 占用了 log.env，原有的 log.env 字段再加一层前缀
+	want := map[string]string{
+		"core":        "a",
+		"env":         "prod",
+		"level":       "tag",
+		"log.level":   "ERROR",
+		"thread_id":   "1",
+		"log.core":    "x",
+		"log.env":     "y",
+		"log.log.env": "z",
+		"task":        "t",
+	}
+	if len(got) != len(want) {
+		t.Errorf("attributes = %v", got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("attribute %s = %s, want %s", key, got[key], value)
+		}
+	}
+}
+
+// Wait 在 Run 发送完剩余数据后返回，Run 未结束时最多等待一个超时时间
+func TestOTLPExporterWait(t *testing.T) {
+	_, endpoint := newGRPCCollector(t)
+	e, err := NewOTLPExporter(runtime.OTLPConfig{Endpoint: endpoint, Insecure: true, Timeout: "500ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Wait(); err == nil {
+		t.Error("Wait returned before Run")
+	}
+
+	e.ExportLogs("a", nil, &monitor.Events{Logs: []string{"x"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	go e.Run(ctx, func(err error) { t.Errorf("export error: %v", err) })
+	cancel()
+	if err := e.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if e.Dropped() != 0 {
+		t.Errorf("dropped %d before shutdown finished", e.Dropped())
+	}
+}
+
+// 核心移除后不再保留它的开始时间，同名核心重新添加时从新的第一帧开始累计
+func TestOTLPExporterForget(t *testing.T) {
+	e, err := NewOTLPExporter(runtime.OTLPConfig{Endpoint: "127.0.0.1:1", Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ExportMetrics("a", nil, &Metrics{Status: newStatus(100, 40, 3, []int{1}, []int{30}), Time: testEpoch})
+	e.Forget("a")
+	if len(e.starts) != 0 {
+		t.Fatalf("starts = %v after Forget", e.starts)
+	}
+	later := testEpoch.Add(time.Minute)
+	if start := e.startOf("a", &Metrics{Time: later}); !start.Equal(later) {
+		t.Errorf("start %v, want %v", start, later)
+	}
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logpb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+const otlpScope = "github.com/B9O2/mtmonitor"
+
+var otlpResource = &resourcepb.Resource{
+	Attributes: []*commonpb.KeyValue{stringAttr("service.name", "mtmonitor")},
+}
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func anyValue(v any) *commonpb.AnyValue {
+	switch v := v.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+}
+
+// coreAttrs 核心名及配置中的标签，标签按名称排序
+func coreAttrs(core string, tags map[string]string) []*commonpb.KeyValue {
+	attrs := []*commonpb.KeyValue{stringAttr("core", core)}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		attrs = append(attrs, stringAttr(name, tags[name]))
+	}
+	return attrs
+}
+
+func withAttr(attrs []*commonpb.KeyValue, key, value string) []*commonpb.KeyValue {
+	return append(slices.Clip(attrs), stringAttr(key, value))
+}
+
+// otlpMetrics 将同名指标的数据点归入同一个 Metric
+type otlpMetrics struct {
+	metrics []*metricpb.Metric
+	index   map[string]*metricpb.Metric
+}
+
+func (om *otlpMetrics) metric(name, unit, description string, newData func() any) *metricpb.Metric {
+	if m, ok := om.index[name]; ok {
+		return m
+	}
+	m := &metricpb.Metric{Name: name, Unit: unit, Description: description}
+	switch data := newData().(type) {
+	case *metricpb.Gauge:
+		m.Data = &metricpb.Metric_Gauge{Gauge: data}
+	case *metricpb.Sum:
+		m.Data = &metricpb.Metric_Sum{Sum: data}
+	}
+	om.metrics = append(om.metrics, m)
+	om.index[name] = m
+	return m
+}
+
+func (om *otlpMetrics) gauge(name, unit, description string, attrs []*commonpb.KeyValue, at time.Time, value float64) {
+	m := om.metric(name, unit, description, func() any { return &metricpb.Gauge{} })
+	gauge := m.GetGauge()
+	gauge.DataPoints = append(gauge.DataPoints, &metricpb.NumberDataPoint{
+		Attributes:   attrs,
+		TimeUnixNano: uint64(at.UnixNano()),
+		Value:        &metricpb.NumberDataPoint_AsDouble{AsDouble: value},
+	})
+}
+
+func (om *otlpMetrics) counter(name, unit, description string, attrs []*commonpb.KeyValue, start, at time.Time, value uint64) {
+	m := om.metric(name, unit, description, func() any {
+		return &metricpb.Sum{
+			AggregationTemporality: metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}
+	})
+	sum := m.GetSum()
+	sum.DataPoints = append(sum.DataPoints, &metricpb.NumberDataPoint{
+		Attributes:        attrs,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		TimeUnixNano:      uint64(at.UnixNano()),
+		Value:             &metricpb.NumberDataPoint_AsInt{AsInt: int64(value)},
+	})
+}
+
+// add 添加一帧的数据点，start 为计数器本次运行的开始时间
+func (om *otlpMetrics) add(core string, tags map[string]string, start time.Time, m *Metrics) {
+	attrs := coreAttrs(core, tags)
+	om.counter("mtmonitor.core.tasks", "{task}", "Tasks submitted to the core.", attrs, start, m.Time, uint64(m.TotalTask))
+	om.counter("mtmonitor.core.results", "{result}", "Results produced by the core.", attrs, start, m.Time, uint64(m.TotalResult))
+	om.counter("mtmonitor.core.retries", "{retry}", "Tasks retried by the core.", attrs, start, m.Time, uint64(m.TotalRetry))
+	om.gauge("mtmonitor.core.retry_queue.size", "{task}", "Tasks waiting in the retry queue.", attrs, m.Time, float64(m.RetrySize))
+	om.gauge("mtmonitor.core.pending", "{task}", "Tasks submitted but not yet finished.", attrs, m.Time, float64(m.Pending))
+	om.gauge("mtmonitor.core.speed", "{result}/s", "Results per second over the last interval.", attrs, m.Time, m.Speed)
+	om.gauge("mtmonitor.core.speed.ewma", "{result}/s", "Smoothed results per second.", attrs, m.Time, m.SpeedEWMA)
+	om.gauge("mtmonitor.core.retry.rate", "{retry}/s", "Retries per second over the last interval.", attrs, m.Time, m.RetryRate)
+	om.gauge("mtmonitor.core.threads", "{thread}", "Threads in the pool by state.", withAttr(attrs, "state", "working"), m.Time, float64(m.Working))
+	om.gauge("mtmonitor.core.threads", "{thread}", "Threads in the pool by state.", withAttr(attrs, "state", "idle"), m.Time, float64(m.Idle))
+	om.gauge("mtmonitor.core.usage", "1", "Share of threads that are working.", attrs, m.Time, m.UsageRate())
+	om.gauge("mtmonitor.core.imbalance", "1", "Gini coefficient of tasks completed per thread.", attrs, m.Time, m.Imbalance)
+
+	issues := make(map[Severity]int)
+	for _, issue := range m.HealthIssues {
+		issues[issue.Severity]++
+	}
+	for _, severity := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		om.gauge("mtmonitor.core.health_issues", "{issue}", "Health issues found in the frame by severity.",
+			withAttr(attrs, "severity", string(severity)), m.Time, float64(issues[severity]))
+	}
+}
+
+func (om *otlpMetrics) resourceMetrics() []*metricpb.ResourceMetrics {
+	return []*metricpb.ResourceMetrics{{
+		Resource: otlpResource,
+		ScopeMetrics: []*metricpb.ScopeMetrics{{
+			Scope:   &commonpb.InstrumentationScope{Name: otlpScope},
+			Metrics: om.metrics,
+		}},
+	}}
+}
+
+func newOTLPMetrics() *otlpMetrics {
+	return &otlpMetrics{index: make(map[string]*metricpb.Metric)}
+}
+
+var otlpSeverities = map[string]logpb.SeverityNumber{
+	"TRACE": logpb.SeverityNumber_SEVERITY_NUMBER_TRACE,
+	"DEBUG": logpb.SeverityNumber_SEVERITY_NUMBER_DEBUG,
+	"INFO":  logpb.SeverityNumber_SEVERITY_NUMBER_INFO,
+	"WARN":  logpb.SeverityNumber_SEVERITY_NUMBER_WARN,
+	"ERROR": logpb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+	"FATAL": logpb.SeverityNumber_SEVERITY_NUMBER_FATAL,
+	"PANIC": logpb.SeverityNumber_SEVERITY_NUMBER_FATAL,
+}
+
+// otlpLogRecord 转换一行日志，日志中没有可解析的时间时使用接收时间
+func otlpLogRecord(core string, tags map[string]string, received time.Time, record LogRecord) *logpb.LogRecord {
+	level := strings.ToUpper(record.Level)
+	if level == "WARNING" {
+		level = "WARN"
+	}
+	attrs := coreAttrs(core, tags)
+	used := make(map[string]bool, len(attrs))
+	for _, attr := range attrs {
+		used[attr.Key] = true
+	}
+	// OTLP 不允许重复的属性键，与核心名、标签或其他日志属性重名时加 log. 前缀，仍然重名时丢弃
+	add := func(key string, value *commonpb.AnyValue) {
+		if used[key] {
+			key = "log." + key
+		}
+		if used[key] {
+			return
+		}
+		used[key] = true
+		attrs = append(attrs, &commonpb.KeyValue{Key: key, Value: value})
+	}
+	if record.Level != "" {
+		add("level", anyValue(record.Level))
+	}
+	if record.ThreadID != nil {
+		add("thread_id", anyValue(*record.ThreadID))
+	}
+	fields := make([]string, 0, len(record.Fields))
+	for name := range record.Fields {
+		fields = append(fields, name)
+	}
+	slices.Sort(fields)
+	for _, name := range fields {
+		add(name, anyValue(record.Fields[name]))
+	}
+
+	lr := &logpb.LogRecord{
+		ObservedTimeUnixNano: uint64(received.UnixNano()),
+		SeverityNumber:       otlpSeverities[level],
+		SeverityText:         record.Level,
+		Body:                 anyValue(record.Message),
+		Attributes:           attrs,
+	}
+	if t, err := time.Parse(time.RFC3339Nano, record.Time); err == nil {
+		lr.TimeUnixNano = uint64(t.UnixNano())
+	} else {
+		lr.TimeUnixNano = lr.ObservedTimeUnixNano
+	}
+	return lr
+}
+
+func resourceLogs(records []*logpb.LogRecord) []*logpb.ResourceLogs {
+	return []*logpb.ResourceLogs{{
+		Resource: otlpResource,
+		ScopeLogs: []*logpb.ScopeLogs{{
+			Scope:      &commonpb.InstrumentationScope{Name: otlpScope},
+			LogRecords: records,
+		}},
+	}}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+// 重试队列连续增长的周期数、趋势与重试比例，以及 retry-queue-growth 规则
+func TestRetryQueueGrowth(t *testing.T) {
+	steps := []struct {
+		size    uint64
+		growing uint
+		issue   bool
+	}{
+		{0, 0, false},
+		{2, 1, false},
+		{4, 2, false},
+		{4, 0, false}, // 不变时归零
+		{6, 1, false},
+		{8, 2, false},
+		{10, 3, true},
+		{3, 0, false}, // 缩小时归零
+		{5, 1, false},
+		{0, 0, false}, // 清空时归零
+	}
+	c, clock := newCollectorWith(t, time.Second, runtime.HealthCheckConfig{RetryGrowthIntervals: 3})
+	for i, step := range steps {
+		status := newStatus(100, uint64(10*i), uint64(i), []int{1}, []int{10 * i})
+		status.RetrySize = step.size
+		m := c.Update(status)
+		clock.advance(time.Second)
+
+		issue := slices.ContainsFunc(m.HealthIssues, func(issue HealthIssue) bool { return issue.Type == "retry-queue-growth" })
+		if m.RetryQueueGrowing != step.growing || issue != step.issue {
+			t.Errorf("frame %d: growing %d, issue %v, want %d, %v", i, m.RetryQueueGrowing, issue, step.growing, step.issue)
+		}
+		if i > 0 && m.RetryRatio != 0.1 {
+			t.Errorf("frame %d: retry ratio %g, want 0.1", i, m.RetryRatio)
+		}
+	}
+}
+
+func TestRetryQueueTrend(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []uint64
+		want  float64
+	}{
+		{"single frame", []uint64{5}, 0},
+		{"flat", []uint64{5, 5, 5}, 0},
+		{"growing", []uint64{0, 2, 4, 6}, 2},
+		{"draining", []uint64{9, 6, 3}, -3},
+		{"noisy growth", []uint64{0, 3, 1, 4}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var history []*Metrics
+			for i, size := range tt.sizes {
+				status := newStatus(0, 0, 0, nil, nil)
+				status.RetrySize = size
+				history = append(history, frameAt(i, status))
+			}
+			if got := retryQueueTrend(history); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("retryQueueTrend() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryStormRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		speed     float64
+		retryRate float64
+		issue     bool
+	}{
+		{"no retries", 10, 0, false},
+		{"below ratio", 10, 4, false},
+		{"at ratio", 10, 5, false},
+		{"above ratio", 10, 6, true},
+		{"retrying without results", 0, 1, true},
+	}
+	rule := &retryStormRule{maxRatio: runtime.DefaultMaxRetryRatio}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Metrics{Status: newStatus(0, 0, 0, nil, nil), Speed: tt.speed, RetryRate: tt.retryRate}
+			if issues := rule.Evaluate(&RuleContext{Current: m}); len(issues) != 0 != tt.issue {
+				t.Errorf("issues %+v, want issue %v", issues, tt.issue)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+// Ring 固定容量的环形缓冲区，写满后覆盖最旧的元素
+type Ring[T any] struct {
+	items []T
+	start int
+	size  int
+}
+
+func (r *Ring[T]) Push(v T) {
+	if len(r.items) == 0 {
+		return
+	}
+	if r.size < len(r.items) {
+		r.items[(r.start+r.size)%len(r.items)] = v
+		r.size++
+		return
+	}
+	r.items[r.start] = v
+	r.start = (r.start + 1) % len(r.items)
+}
+
+func (r *Ring[T]) Len() int {
+	return r.size
+}
+
+func (r *Ring[T]) At(i int) T {
+	return r.items[(r.start+i)%len(r.items)]
+}
+
+func (r *Ring[T]) Clear() {
+	clear(r.items)
+	r.start, r.size = 0, 0
+}
+
+func NewRing[T any](capacity int) *Ring[T] {
+	return &Ring[T]{items: make([]T, capacity)}
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		pushes   int
+		want     []int
+	}{
+		{"empty", 3, 0, []int{}},
+		{"partial", 3, 2, []int{0, 1}},
+		{"full", 3, 3, []int{0, 1, 2}},
+		{"wrapped", 3, 7, []int{4, 5, 6}},
+		{"zero capacity", 0, 2, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRing[int](tt.capacity)
+			for i := range tt.pushes {
+				r.Push(i)
+			}
+			got := []int{}
+			for i := range r.Len() {
+				got = append(got, r.At(i))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("items %v, want %v", got, tt.want)
+			}
+			r.Clear()
+			if r.Len() != 0 {
+				t.Errorf("%d items after Clear", r.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+type RuleContext struct {
+	Core     string
+	Current  *Metrics
+	Previous *Metrics   // 上一帧，首帧时为 nil
+	History  []*Metrics // 窗口内的历史帧，由旧到新，包含 Current
+	Interval time.Duration
+	Config   runtime.HealthCheckConfig
+	Runs     []RunSummary
+}
+
+// HealthRule 健康规则，每个核心持有独立的实例，因此可以在多次评估之间保存状态
+type HealthRule interface {
+	Name() string
+	Evaluate(ctx *RuleContext) HealthIssues
+}
+
+type RuleFactory func(cfg runtime.HealthCheckConfig) HealthRule
+
+var (
+	ruleRegistry = make(map[string]RuleFactory)
+	ruleOrder    []string
+)
+
+// RegisterRule 注册规则，所有核心默认启用，可通过 disabled_rules 关闭。
+// runtime.HealthRules 以外的名称同时登记到 runtime，配置校验才能识别。
+func RegisterRule(name string, factory RuleFactory) error {
+	if _, ok := ruleRegistry[name]; ok {
+		return fmt.Errorf("health rule %s already registered", name)
+	}
+	if !slices.Contains(runtime.HealthRules, name) {
+		if err := runtime.RegisterHealthRule(name); err != nil {
+			return err
+		}
+	}
+	ruleRegistry[name] = factory
+	ruleOrder = append(ruleOrder, name)
+	return nil
+}
+
+// mustRegisterRule 在 init 中注册内置规则，名称冲突时 panic
+func mustRegisterRule(name string, factory RuleFactory) {
+	if err := RegisterRule(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+func RegisteredRules() []string {
+	return slices.Clone(ruleOrder)
+}
+
+func NewRules(cfg runtime.HealthCheckConfig) ([]HealthRule, error) {
+	var rules []HealthRule
+	for _, name := range cfg.DisabledRules {
+		if _, ok := ruleRegistry[name]; !ok {
+			return nil, fmt.Errorf("unknown health rule %q in disabled_rules", name)
+		}
+	}
+	for name := range cfg.Severity {
+		if _, ok := ruleRegistry[name]; !ok && !slices.Contains(runtime.HealthIssueTypes, name) {
+			return nil, fmt.Errorf("unknown health rule %q in severity", name)
+		}
+	}
+	for _, name := range ruleOrder {
+		if !slices.Contains(cfg.DisabledRules, name) {
+			rules = append(rules, ruleRegistry[name](cfg))
+		}
+	}
+	for _, rc := range cfg.Rules {
+		rule, err := NewThresholdRule(rc)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// IssueStreamStale 数据流停滞，由 Web 服务检测，严重程度同样可以通过 severity 配置
+const IssueStreamStale = runtime.IssueStreamStale
+
+var defaultEscalation = map[string]runtime.SeverityConfig{
+	"thread-blocking": {WarningAfter: 3, CriticalAfter: 30},
+}
+
+// severityConfigs 按问题类型汇总严重程度配置，配置中的非零字段覆盖默认值
+func severityConfigs(cfg runtime.HealthCheckConfig) map[string]runtime.SeverityConfig {
+	configs := make(map[string]runtime.SeverityConfig)
+	for issueType, sc := range defaultEscalation {
+		configs[issueType] = sc
+	}
+	for name, sc := range cfg.Severity {
+		merged := configs[name]
+		if sc.Severity != "" {
+			merged.Severity = sc.Severity
+		}
+		if sc.WarningAfter > 0 {
+			merged.WarningAfter = sc.WarningAfter
+		}
+		if sc.CriticalAfter > 0 {
+			merged.CriticalAfter = sc.CriticalAfter
+		}
+		configs[name] = merged
+	}
+	for _, rc := range cfg.Rules {
+		configs["rule:"+rc.Name] = rc.SeverityConfig()
+	}
+	return configs
+}
+
+func init() {
+	mustRegisterRule("thread-blocking", func(cfg runtime.HealthCheckConfig) HealthRule {
+		return &threadBlockingRule{maxTimes: cfg.MaxWorkingIntervalTimesOrDefault()}
+	})
+	mustRegisterRule("no-threads-working", func(cfg runtime.HealthCheckConfig) HealthRule {
+		return &noThreadsWorkingRule{}
+	})
+	mustRegisterRule("low-thread-usage", func(cfg runtime.HealthCheckConfig) HealthRule {
+		return &lowUsageRule{minUsage: cfg.MinUsageRate}
+	})
+	mustRegisterRule("thread-imbalance", func(cfg runtime.HealthCheckConfig) HealthRule {
+		maxImbalance := cfg.MaxImbalance
+		if maxImbalance == 0 {
+			maxImbalance = runtime.DefaultMaxImbalance
+		}
+		return &imbalanceRule{maxImbalance: maxImbalance}
+	})
+	mustRegisterRule("retry-storm", func(cfg runtime.HealthCheckConfig) HealthRule {
+		maxRatio := cfg.MaxRetryRatio
+		if maxRatio == 0 {
+			maxRatio = runtime.DefaultMaxRetryRatio
+		}
+		return &retryStormRule{maxRatio: maxRatio}
+	})
+	mustRegisterRule("retry-queue-growth", func(cfg runtime.HealthCheckConfig) HealthRule {
+		intervals := cfg.RetryGrowthIntervals
+		if intervals == 0 {
+			intervals = runtime.DefaultRetryGrowthIntervals
+		}
+		return &retryGrowthRule{intervals: intervals}
+	})
+	mustRegisterRule("core-restarted", func(cfg runtime.HealthCheckConfig) HealthRule {
+		return &coreRestartedRule{}
+	})
+}
+
+// threadBlockingRule 线程处理同一个任务的时间超过 max_working_interval_times 个周期
+type threadBlockingRule struct {
+	maxTimes uint
+}
+
+func (r *threadBlockingRule) Name() string {
+	return "thread-blocking"
+}
+
+func (r *threadBlockingRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	limit := time.Duration(r.maxTimes) * ctx.Interval
+	for tid, since := range ctx.Current.ThreadsBusySince {
+		if since == nil {
+			continue
+		}
+		if busy := ctx.Current.Time.Sub(*since); busy >= limit {
+			issues.ThreadBlockingIssue(
+				tid,
+				fmt.Sprintf("Thread %d stuck on the same task for %s (limit %s).", tid, roundDuration(busy), limit),
+				SeverityInfo,
+			)
+		}
+	}
+	return issues
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Second)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// noThreadsWorkingRule 所有线程空闲但仍有任务未完成（或尚未收到任务），任务全部完成时不再报告
+type noThreadsWorkingRule struct{}
+
+func (r *noThreadsWorkingRule) Name() string {
+	return "no-threads-working"
+}
+
+func (r *noThreadsWorkingRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	if ctx.Current.Working == 0 && !ctx.Current.Completed {
+		description := "All threads are idle, which may indicate a lack of tasks or an issue with task distribution."
+		if ctx.Current.PoolSize() == 0 {
+			description = "The thread pool is empty, no tasks can be processed."
+		}
+		issues.Append(r.Name(), "No Threads Working", description, SeverityCritical)
+	}
+	return issues
+}
+
+type lowUsageRule struct {
+	minUsage float32
+}
+
+func (r *lowUsageRule) Name() string {
+	return "low-thread-usage"
+}
+
+func (r *lowUsageRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	if ctx.Current.Working == 0 {
+		return issues
+	}
+	usage := float32(ctx.Current.UsageRate())
+	if usage < r.minUsage {
+		issues.Append(
+			r.Name(),
+			"Low Thread Usage",
+			fmt.Sprintf("Only %.2f%% of threads are working, which is below the minimum usage rate of %.2f%%.", usage*100, r.minUsage*100),
+			SeverityWarning,
+		)
+	}
+	return issues
+}
+
+// minImbalanceTasks 平均每个线程至少完成多少任务后才评估负载分布，避免启动阶段误报
+const minImbalanceTasks = 10
+
+type imbalanceRule struct {
+	maxImbalance float64
+}
+
+func (r *imbalanceRule) Name() string {
+	return "thread-imbalance"
+}
+
+func (r *imbalanceRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	m := ctx.Current
+	if m.PoolSize() < 2 || float64(m.TotalResult) < float64(minImbalanceTasks*m.PoolSize()) || m.Imbalance <= r.maxImbalance {
+		return issues
+	}
+	issues.Append(
+		r.Name(),
+		"Thread Imbalance",
+		fmt.Sprintf("Work is unevenly distributed across threads (gini %.2f, limit %.2f). Hot threads: %v, cold threads: %v.",
+			m.Imbalance, r.maxImbalance, m.HotThreads, m.ColdThreads),
+		SeverityWarning,
+	)
+	return issues
+}
+
+type retryStormRule struct {
+	maxRatio float64
+}
+
+func (r *retryStormRule) Name() string {
+	return "retry-storm"
+}
+
+func (r *retryStormRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	m := ctx.Current
+	if m.RetryRate == 0 || m.RetryRate <= m.Speed*r.maxRatio {
+		return issues
+	}
+	issues.Append(
+		r.Name(),
+		"Retry Storm",
+		fmt.Sprintf("Retrying %.2f tasks/s while producing %.2f results/s, above %.0f%% of throughput. A downstream dependency may be failing.",
+			m.RetryRate, m.Speed, r.maxRatio*100),
+		SeverityWarning,
+	)
+	return issues
+}
+
+type retryGrowthRule struct {
+	intervals uint
+}
+
+func (r *retryGrowthRule) Name() string {
+	return "retry-queue-growth"
+}
+
+func (r *retryGrowthRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	m := ctx.Current
+	if m.RetryQueueGrowing < r.intervals {
+		return issues
+	}
+	issues.Append(
+		r.Name(),
+		"Retry Queue Growing",
+		fmt.Sprintf("The retry queue has kept growing for %d intervals and now holds %d tasks (trend %+.2f/s).",
+			m.RetryQueueGrowing, m.RetrySize, m.RetryQueueTrend),
+		SeverityWarning,
+	)
+	return issues
+}
+
+type coreRestartedRule struct{}
+
+func (r *coreRestartedRule) Name() string {
+	return "core-restarted"
+}
+
+func (r *coreRestartedRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	if len(ctx.Runs) == 0 {
+		return issues
+	}
+	previous := ctx.Runs[len(ctx.Runs)-1]
+	if ctx.Current.Time.Sub(previous.EndedAt) > ctx.Config.WindowDuration() {
+		return issues
+	}
+	issues.Append(
+		r.Name(),
+		"Core Restarted",
+		fmt.Sprintf("Counters were reset after %s, the previous run finished %d/%d tasks with %d retries.",
+			previous.EndedAt.Format(time.DateTime), previous.TotalResult, previous.TotalTask, previous.TotalRetry),
+		SeverityWarning,
+	)
+	return issues
+}
+
+func MetricValue(metric string, ctx *RuleContext) (value float64, ok bool) {
+	m := ctx.Current
+	switch metric {
+	case "speed":
+		return m.Speed, true
+	case "speed_ewma":
+		return m.SpeedEWMA, true
+	case "speed_avg_1m":
+		return m.SpeedStats.Avg1m, true
+	case "speed_avg_5m":
+		return m.SpeedStats.Avg5m, true
+	case "speed_avg_15m":
+		return m.SpeedStats.Avg15m, true
+	case "speed_p50":
+		return m.SpeedStats.P50, true
+	case "speed_p95":
+		return m.SpeedStats.P95, true
+	case "retry_delta":
+		if ctx.Previous == nil || ctx.Previous.Status == nil {
+			return 0, false
+		}
+		if m.TotalRetry < ctx.Previous.TotalRetry {
+			return float64(m.TotalRetry), true
+		}
+		return float64(m.TotalRetry) - float64(ctx.Previous.TotalRetry), true
+	case "retry_size":
+		return float64(m.RetrySize), true
+	case "retry_rate":
+		return m.RetryRate, ctx.Previous != nil
+	case "retry_ratio":
+		return m.RetryRatio, true
+	case "retry_queue_trend":
+		return m.RetryQueueTrend, len(ctx.History) > 1
+	case "backlog":
+		return float64(m.Pending), true
+	case "usage":
+		// 空线程池的使用率没有意义，不参与比较
+		return m.UsageRate(), m.PoolSize() > 0
+	case "working":
+		return float64(m.Working), true
+	case "idle":
+		return float64(m.Idle), true
+	case "error_log_rate":
+		return m.ErrorLogRate, true
+	case "imbalance":
+		return m.Imbalance, m.PoolSize() > 1
+	case "progress_pct":
+		return m.ProgressPct, true
+	case "eta":
+		if m.ETA == nil {
+			return 0, false
+		}
+		return *m.ETA, true
+	}
+	return 0, false
+}
+
+func compare(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+// ThresholdRule 配置文件中声明的阈值规则，条件持续满足 for 指定的时间后产生健康问题
+type ThresholdRule struct {
+	config runtime.HealthRuleConfig
+	since  time.Time // 条件开始满足的时间，不满足时为零值
+}
+
+func (r *ThresholdRule) Name() string {
+	return r.config.Name
+}
+
+func (r *ThresholdRule) Evaluate(ctx *RuleContext) HealthIssues {
+	var issues HealthIssues
+	rc := r.config
+	value, ok := MetricValue(rc.Metric, ctx)
+	if !ok || !compare(value, rc.Op, rc.Threshold) {
+		r.since = time.Time{}
+		return issues
+	}
+	if r.since.IsZero() {
+		r.since = ctx.Current.Time
+	}
+	lasted := ctx.Current.Time.Sub(r.since)
+	if lasted < rc.ForDuration() {
+		return issues
+	}
+
+	formatted := strconv.FormatFloat(value, 'f', -1, 64)
+	description := rc.Message
+	if description == "" {
+		description = fmt.Sprintf("%s %s %g for %s (current %s).", rc.Metric, rc.Op, rc.Threshold, roundDuration(lasted), formatted)
+	} else {
+		description = strings.ReplaceAll(description, "{value}", formatted)
+	}
+	issues.Append("rule:"+rc.Name, rc.Name, description, Severity(rc.Severity))
+	return issues
+}
+
+func NewThresholdRule(rc runtime.HealthRuleConfig) (*ThresholdRule, error) {
+	if err := runtime.CheckMetric(rc.Metric); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", rc.Name, err)
+	}
+	if err := runtime.CheckOp(rc.Op); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", rc.Name, err)
+	}
+	if rc.Severity == "" {
+		rc.Severity = string(SeverityWarning)
+	}
+	return &ThresholdRule{config: rc}, nil
+}
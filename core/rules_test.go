@@ -0,0 +1,169 @@
+package core
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+// runtime 声明的内置规则均已注册，配置校验与 NewRules 的判断一致
+func TestRuleNamesValidated(t *testing.T) {
+	if err := registerPluginRule(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range runtime.HealthRules {
+		if !slices.Contains(RegisteredRules(), name) {
+			t.Errorf("health rule %s is declared in runtime but not registered", name)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		disabled []string
+		severity map[string]runtime.SeverityConfig
+		valid    bool
+	}{
+		{"builtin rules", RegisteredRules(), nil, true},
+		{"plugin rule", []string{"plugin-rule"}, nil, true},
+		{"stream-stale severity", nil, map[string]runtime.SeverityConfig{IssueStreamStale: {Severity: "critical"}}, true},
+		{"stream-stale disabled", []string{IssueStreamStale}, nil, false},
+		{"unknown disabled", []string{"nope"}, nil, false},
+		{"unknown severity", nil, map[string]runtime.SeverityConfig{"nope": {}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := runtime.HealthCheckConfig{DisabledRules: tt.disabled, Severity: tt.severity}
+			cfg := &runtime.Config{
+				Credentials: map[string]runtime.CredentialConfig{"c": {}},
+				Cores: map[string]runtime.CoreConfig{
+					"a": {Host: "127.0.0.1", Port: 9001, Interval: "1s", Credential: "c", HealthCheck: hc},
+				},
+			}
+			errs := cfg.Validate()
+			if valid := len(errs) == 0; valid != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", errs, tt.valid)
+			}
+			if _, err := NewRules(hc); (err == nil) != tt.valid {
+				t.Errorf("NewRules() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+type nopRule struct{}
+
+func (nopRule) Name() string                           { return "plugin-rule" }
+func (nopRule) Evaluate(ctx *RuleContext) HealthIssues { return nil }
+
+// registerPluginRule 模拟在 core 之外注册的规则，只注册一次以便重复运行测试
+var registerPluginRule = sync.OnceValue(func() error {
+	return RegisterRule("plugin-rule", func(cfg runtime.HealthCheckConfig) HealthRule { return nopRule{} })
+})
+
+func TestRegisterRule(t *testing.T) {
+	if err := registerPluginRule(); err != nil {
+		t.Fatal(err)
+	}
+	factory := func(cfg runtime.HealthCheckConfig) HealthRule { return nopRule{} }
+	for _, name := range []string{"thread-blocking", "plugin-rule", IssueStreamStale} {
+		if err := RegisterRule(name, factory); err == nil {
+			t.Errorf("RegisterRule(%q) succeeded", name)
+		}
+	}
+}
+
+// newStatus 构造测试用的核心状态
+func newStatus(task, result, retry uint64, threadsStatus, threadsCount []int) *monitor.Status {
+	detail := &monitor.ThreadsDetail{
+		ThreadsStatus: make([]int32, len(threadsStatus)),
+		ThreadsCount:  make([]uint64, len(threadsCount)),
+	}
+	for i, ts := range threadsStatus {
+		detail.ThreadsStatus[i] = int32(ts)
+	}
+	for i, n := range threadsCount {
+		detail.ThreadsCount[i] = uint64(n)
+	}
+	return &monitor.Status{TotalTask: task, TotalResult: result, TotalRetry: retry, ThreadsDetail: detail}
+}
+
+// frameAt 第 i 秒的一帧，时间从 testEpoch 开始
+func frameAt(i int, status *monitor.Status) *Metrics {
+	return &Metrics{Status: status, Time: testEpoch.Add(time.Duration(i) * time.Second)}
+}
+
+// fullContext 所有阈值指标都可以计算的评估上下文
+func fullContext() *RuleContext {
+	eta := 30.0
+	previous := &Metrics{Status: newStatus(100, 40, 2, []int{1, 0}, []int{30, 10})}
+	current := &Metrics{Status: newStatus(100, 40, 5, []int{1, 0}, []int{30, 10}), ETA: &eta}
+	return &RuleContext{Current: current, Previous: previous, History: []*Metrics{previous, current}}
+}
+
+// 配置校验接受的每个指标与运算符都能被阈值规则计算
+func TestThresholdTablesMatchEvaluation(t *testing.T) {
+	ctx := fullContext()
+	for _, metric := range runtime.ThresholdMetrics {
+		if _, ok := MetricValue(metric, ctx); !ok {
+			t.Errorf("MetricValue(%q) not available, but the metric passes validation", metric)
+		}
+	}
+	if _, ok := MetricValue("nope", ctx); ok {
+		t.Error("MetricValue accepted an unknown metric")
+	}
+	if got, _ := MetricValue("retry_delta", ctx); got != 3 {
+		t.Errorf("retry_delta = %g, want 3", got)
+	}
+
+	tests := []struct {
+		op    string
+		value float64
+		want  bool
+	}{
+		{">", 2, true}, {">", 1, false},
+		{">=", 1, true}, {">=", 0, false},
+		{"<", 0, true}, {"<", 1, false},
+		{"<=", 1, true}, {"<=", 2, false},
+		{"==", 1, true}, {"==", 2, false},
+		{"!=", 2, true}, {"!=", 1, false},
+	}
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		covered[tt.op] = true
+		if got := compare(tt.value, tt.op, 1); got != tt.want {
+			t.Errorf("compare(%g %s 1) = %v, want %v", tt.value, tt.op, got, tt.want)
+		}
+	}
+	for _, op := range runtime.RuleOps {
+		if !covered[op] {
+			t.Errorf("operator %q not covered", op)
+		}
+	}
+}
+
+func TestNewThresholdRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rc   runtime.HealthRuleConfig
+		err  error
+	}{
+		{"valid", runtime.HealthRuleConfig{Name: "r", Metric: "speed", Op: "<"}, nil},
+		{"unknown metric", runtime.HealthRuleConfig{Name: "r", Metric: "nope", Op: "<"}, runtime.CheckMetric("nope")},
+		{"unknown op", runtime.HealthRuleConfig{Name: "r", Metric: "speed", Op: "=~"}, runtime.CheckOp("=~")},
+	}
+	for _, tt := range tests {
+		rule, err := NewThresholdRule(tt.rc)
+		switch {
+		case tt.err == nil && err != nil:
+			t.Errorf("%s: NewThresholdRule() error = %v", tt.name, err)
+		case tt.err != nil && (err == nil || err.Error() != "rule r: "+tt.err.Error()):
+			t.Errorf("%s: NewThresholdRule() error = %v, want the validation message %q", tt.name, err, tt.err)
+		case tt.err == nil && rule.config.Severity != "warning":
+			t.Errorf("%s: default severity = %q", tt.name, rule.config.Severity)
+		}
+	}
+}
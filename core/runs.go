@@ -0,0 +1,56 @@
+package core
+
+import (
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+)
+
+const maxRunSummaries = 20
+
+// RunSummary 核心一次运行（两次重启之间）的最终统计
+type RunSummary struct {
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at"`
+	TotalTask   uint64    `json:"total_task"`
+	TotalResult uint64    `json:"total_result"`
+	TotalRetry  uint64    `json:"total_retry"`
+}
+
+type CoreRestart struct {
+	Previous RunSummary `json:"previous"`
+	Counters []string   `json:"counters"`
+}
+
+type JobCompletion struct {
+	CompletedAt time.Time `json:"completed_at"`
+	Duration    float64   `json:"duration"`
+	TotalTask   uint64    `json:"total_task"`
+	TotalResult uint64    `json:"total_result"`
+	TotalRetry  uint64    `json:"total_retry"`
+}
+
+// resetCounters 返回相比上一帧变小的累计计数器，非空表示核心已经重启
+func resetCounters(prev, cur *monitor.Status) []string {
+	var counters []string
+	if cur.TotalTask < prev.TotalTask {
+		counters = append(counters, "total_task")
+	}
+	if cur.TotalResult < prev.TotalResult {
+		counters = append(counters, "total_result")
+	}
+	if cur.TotalRetry < prev.TotalRetry {
+		counters = append(counters, "total_retry")
+	}
+	return counters
+}
+
+func newRunSummary(started time.Time, last *Metrics) RunSummary {
+	return RunSummary{
+		StartedAt:   started,
+		EndedAt:     last.Time,
+		TotalTask:   uint64(last.TotalTask),
+		TotalResult: uint64(last.TotalResult),
+		TotalRetry:  uint64(last.TotalRetry),
+	}
+}
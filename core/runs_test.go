@@ -0,0 +1,96 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResetCounters(t *testing.T) {
+	prev := newStatus(100, 50, 5, nil, nil)
+	tests := []struct {
+		name string
+		cur  [3]uint64
+		want []string
+	}{
+		{"growing", [3]uint64{120, 60, 5}, nil},
+		{"unchanged", [3]uint64{100, 50, 5}, nil},
+		{"task only", [3]uint64{10, 50, 5}, []string{"total_task"}},
+		{"all reset", [3]uint64{10, 3, 0}, []string{"total_task", "total_result", "total_retry"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := newStatus(tt.cur[0], tt.cur[1], tt.cur[2], nil, nil)
+			if got := resetCounters(prev, cur); !slices.Equal(got, tt.want) {
+				t.Errorf("resetCounters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 计数器重置时记录上一次运行，速度按重启后的结果数计算，重连后同样能发现重启
+func TestCoreRestart(t *testing.T) {
+	tests := []struct {
+		name      string
+		reconnect bool
+		speed     float64
+	}{
+		{"same connection", false, 3},
+		{"after reconnect", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, clock := newTestCollector(t, time.Second)
+			started := clock.now
+			c.Update(newStatus(100, 40, 4, []int{1}, []int{40}))
+			clock.advance(time.Second)
+			c.Update(newStatus(100, 50, 5, []int{1}, []int{50}))
+			ended := clock.now
+			if tt.reconnect {
+				c.Reset()
+			}
+			clock.advance(time.Second)
+			m := c.Update(newStatus(10, 3, 0, []int{1}, []int{3}))
+
+			if m.Speed != tt.speed {
+				t.Errorf("speed = %g, want %g", m.Speed, tt.speed)
+			}
+			want := RunSummary{StartedAt: started, EndedAt: ended, TotalTask: 100, TotalResult: 50, TotalRetry: 5}
+			if runs := c.Runs(); len(runs) != 1 || runs[0] != want {
+				t.Errorf("runs %+v, want [%+v]", runs, want)
+			}
+			var restarts []CoreRestart
+			for _, event := range m.CoreEvents {
+				if event.Type == "core_restarted" {
+					restarts = append(restarts, event.Data.(CoreRestart))
+				}
+			}
+			if len(restarts) != 1 || restarts[0].Previous != want || len(restarts[0].Counters) != 3 {
+				t.Errorf("core_restarted events %+v", restarts)
+			}
+			if !slices.ContainsFunc(m.HealthIssues, func(issue HealthIssue) bool { return issue.Type == "core-restarted" }) {
+				t.Errorf("no core-restarted issue in %+v", m.HealthIssues)
+			}
+
+			// 下一帧正常增长，不再视为重启
+			clock.advance(time.Second)
+			m = c.Update(newStatus(10, 5, 0, []int{1}, []int{5}))
+			if m.Speed != 2 || len(c.Runs()) != 1 || slices.ContainsFunc(m.CoreEvents, func(event CoreEvent) bool { return event.Type == "core_restarted" }) {
+				t.Errorf("after restart: speed %g, %d runs, events %v", m.Speed, len(c.Runs()), eventNames(m.CoreEvents))
+			}
+		})
+	}
+}
+
+func TestRunSummariesBounded(t *testing.T) {
+	c, clock := newTestCollector(t, time.Second)
+	for i := range 2 * (maxRunSummaries + 5) {
+		// 交替上报 10 与 5 个结果，每两帧重启一次
+		c.Update(newStatus(10, uint64(10-5*(i%2)), 0, nil, nil))
+		clock.advance(time.Second)
+	}
+	runs := c.Runs()
+	if len(runs) != maxRunSummaries || runs[len(runs)-1].TotalResult != 10 {
+		t.Errorf("%d runs, latest %+v", len(runs), runs[len(runs)-1])
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 段文件：8 字节的文件头（segmentMagic 与字段数）后接定长记录，
+// 每条记录为 CRC32、时间（Unix 纳秒）以及按 historyFields 顺序排列的字段值，均为小端序。
+// 文件只在末尾追加，崩溃时最多损坏最后一条记录，读取时忽略、追加前截掉。
+const (
+	segmentMagic      = "MTS1"
+	segmentHeaderSize = 8
+)
+
+var recordSize = 4 + 8 + 8*len(historyFields)
+
+// errCorruptSegment 段文件头与当前格式不符，文件中的记录都无法读取
+var errCorruptSegment = errors.New("corrupt segment header")
+
+func segmentHeader() []byte {
+	header := make([]byte, segmentHeaderSize)
+	copy(header, segmentMagic)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(historyFields)))
+	return header
+}
+
+func encodeRecords(samples []HistorySample) []byte {
+	buf := make([]byte, len(samples)*recordSize)
+	for i := range samples {
+		record := buf[i*recordSize : (i+1)*recordSize]
+		binary.LittleEndian.PutUint64(record[4:], uint64(samples[i].Time.UnixNano()))
+		for j, f := range historyFields {
+			binary.LittleEndian.PutUint64(record[12+8*j:], math.Float64bits(*f.value(&samples[i])))
+		}
+		binary.LittleEndian.PutUint32(record, crc32.ChecksumIEEE(record[4:]))
+	}
+	return buf
+}
+
+// readSegment 读取段文件中的记录，同时返回完整记录结束的位置。
+// 文件头不完整时视为空文件，不完整或校验失败的记录及其之后的内容都被忽略。
+func readSegment(path string) ([]HistorySample, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) < segmentHeaderSize {
+		return nil, 0, nil
+	}
+	if string(data[:4]) != segmentMagic {
+		return nil, 0, fmt.Errorf("segment %s: %w: bad magic", path, errCorruptSegment)
+	}
+	if n := binary.LittleEndian.Uint32(data[4:]); int(n) != len(historyFields) {
+		return nil, 0, fmt.Errorf("segment %s: %w: %d fields, expected %d", path, errCorruptSegment, n, len(historyFields))
+	}
+
+	var samples []HistorySample
+	offset := segmentHeaderSize
+	for ; offset+recordSize <= len(data); offset += recordSize {
+		record := data[offset : offset+recordSize]
+		if crc32.ChecksumIEEE(record[4:]) != binary.LittleEndian.Uint32(record) {
+			break
+		}
+		sample := HistorySample{Time: time.Unix(0, int64(binary.LittleEndian.Uint64(record[4:])))}
+		for j, f := range historyFields {
+			*f.value(&sample) = math.Float64frombits(binary.LittleEndian.Uint64(record[12+8*j:]))
+		}
+		samples = append(samples, sample)
+	}
+	return samples, int64(offset), nil
+}
+
+// openSegment 打开段文件用于追加，截掉上次崩溃时写了一半的记录。
+// 文件头损坏的段改名为 .corrupt 保留，重新开始一个空段，否则之后的追加都会失败。
+func openSegment(path string) (*os.File, error) {
+	_, valid, err := readSegment(path)
+	if errors.Is(err, errCorruptSegment) {
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			return nil, err
+		}
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if valid == 0 {
+		if _, err := f.Write(segmentHeader()); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
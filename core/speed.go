@@ -0,0 +1,116 @@
+package core
+
+import (
+	"slices"
+	"time"
+)
+
+// SpeedStats 服务端根据历史速度计算的吞吐统计，终端、REST 与 WebSocket 看到的数值一致
+type SpeedStats struct {
+	Avg1m  float64 `json:"avg_1m"`
+	Avg5m  float64 `json:"avg_5m"`
+	Avg15m float64 `json:"avg_15m"`
+	Min    float64 `json:"min"` // 以下四项基于统计窗口
+	Max    float64 `json:"max"`
+	P50    float64 `json:"p50"`
+	P95    float64 `json:"p95"`
+	Window float64 `json:"window"`
+	Peak   float64 `json:"peak"`
+}
+
+// maxSpeedSamples 速度缓冲区的容量上限，避免极短的周期占用过多内存
+const maxSpeedSamples = 100000
+
+const speedAlpha = 0.3
+
+type speedSample struct {
+	time  time.Time
+	speed float64
+}
+
+type speedTracker struct {
+	samples *Ring[speedSample]
+	window  time.Duration
+	ewma    float64
+	peak    float64
+}
+
+func (st *speedTracker) Push(now time.Time, speed float64) {
+	st.samples.Push(speedSample{time: now, speed: speed})
+	if st.samples.Len() == 1 {
+		st.ewma = speed
+	} else {
+		st.ewma = speedAlpha*speed + (1-speedAlpha)*st.ewma
+	}
+	st.peak = max(st.peak, speed)
+}
+
+// ResetPeak 新连接建立时重新计算峰值
+func (st *speedTracker) ResetPeak() {
+	st.peak = 0
+}
+
+func (st *speedTracker) average(now time.Time, span time.Duration) float64 {
+	var sum float64
+	var n int
+	for i := st.samples.Len() - 1; i >= 0; i-- {
+		sample := st.samples.At(i)
+		if now.Sub(sample.time) >= span {
+			break
+		}
+		sum += sample.speed
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func (st *speedTracker) Stats(now time.Time) SpeedStats {
+	var speeds []float64
+	for i := st.samples.Len() - 1; i >= 0; i-- {
+		sample := st.samples.At(i)
+		if now.Sub(sample.time) >= st.window {
+			break
+		}
+		speeds = append(speeds, sample.speed)
+	}
+	slices.Sort(speeds)
+
+	stats := SpeedStats{
+		Avg1m:  st.average(now, time.Minute),
+		Avg5m:  st.average(now, 5*time.Minute),
+		Avg15m: st.average(now, 15*time.Minute),
+		P50:    percentile(speeds, 50),
+		P95:    percentile(speeds, 95),
+		Window: st.window.Seconds(),
+		Peak:   st.peak,
+	}
+	if len(speeds) > 0 {
+		stats.Min = speeds[0]
+		stats.Max = speeds[len(speeds)-1]
+	}
+	return stats
+}
+
+// newSpeedTracker 缓冲区容量覆盖 15 分钟与统计窗口中较长的一个
+func newSpeedTracker(interval, window time.Duration) *speedTracker {
+	span := max(15*time.Minute, window)
+	capacity := maxSpeedSamples
+	if interval > 0 {
+		capacity = min(int(span/interval)+1, maxSpeedSamples)
+	}
+	return &speedTracker{
+		samples: NewRing[speedSample](capacity),
+		window:  window,
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 50, 0},
+		{[]float64{7}, 95, 7},
+		{sorted, 0, 1},
+		{sorted, 50, 5},
+		{sorted, 95, 10},
+		{sorted, 100, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.values, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %g) = %g, want %g", tt.values, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestSpeedStats(t *testing.T) {
+	base := testEpoch
+	// 每秒一个速度 0..19，统计窗口 10 秒只包含 10..19
+	st := newSpeedTracker(time.Second, 10*time.Second)
+	for i := range 20 {
+		st.Push(base.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	now := base.Add(19 * time.Second)
+	want := SpeedStats{Avg1m: 9.5, Avg5m: 9.5, Avg15m: 9.5, Min: 10, Max: 19, P50: 14, P95: 19, Window: 10, Peak: 19}
+	if got := st.Stats(now); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// 超过 1 分钟的样本不计入 1 分钟均值
+	later := now.Add(time.Minute)
+	st.Push(later, 100)
+	got := st.Stats(later)
+	if got.Avg1m != 100 || got.Avg5m != (190+100)/21.0 || got.Min != 100 || got.Peak != 100 {
+		t.Errorf("Stats() after a minute = %+v", got)
+	}
+
+	st.ResetPeak()
+	if got := st.Stats(later); got.Peak != 0 || got.Max != 100 {
+		t.Errorf("Stats() after ResetPeak = %+v", got)
+	}
+}
+
+func TestSpeedEWMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		speeds []float64
+		want   float64
+	}{
+		{"first sample", []float64{10}, 10},
+		{"drop", []float64{10, 0}, 7},
+		{"rise", []float64{0, 10}, 3},
+		{"steady", []float64{5, 5, 5}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newSpeedTracker(time.Second, time.Minute)
+			for i, speed := range tt.speeds {
+				st.Push(time.Unix(int64(i), 0), speed)
+			}
+			if diff := st.ewma - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("ewma = %g, want %g", st.ewma, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpeedTrackerCapacity(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		window   time.Duration
+		want     int
+	}{
+		{time.Second, time.Minute, 901},
+		{time.Second, time.Hour, 3601},
+		{time.Millisecond, time.Hour, maxSpeedSamples},
+		{0, time.Minute, maxSpeedSamples},
+	}
+	for _, tt := range tests {
+		if got := len(newSpeedTracker(tt.interval, tt.window).samples.items); got != tt.want {
+			t.Errorf("newSpeedTracker(%s, %s) capacity %d, want %d", tt.interval, tt.window, got, tt.want)
+		}
+	}
+}
+
+// 重连后的首帧没有速度，不计入统计，峰值从新连接开始计算
+func TestCollectorSpeedStats(t *testing.T) {
+	c, clock := newTestCollector(t, time.Second)
+	for _, result := range []uint64{0, 10, 14} {
+		c.Update(newStatus(100, result, 0, []int{1}, []int{int(result)}))
+		clock.advance(time.Second)
+	}
+	c.Reset()
+	m := c.Update(newStatus(100, 30, 0, []int{1}, []int{30}))
+	if m.Speed != 0 || m.SpeedStats.Peak != 0 || m.SpeedStats.Min != 4 || m.SpeedStats.Max != 10 {
+		t.Errorf("first frame after reconnect: speed %g, stats %+v", m.Speed, m.SpeedStats)
+	}
+	clock.advance(time.Second)
+	m = c.Update(newStatus(100, 32, 0, []int{1}, []int{32}))
+	if m.SpeedStats.Peak != 2 || m.SpeedStats.Min != 2 || m.SpeedStats.Avg1m != 16.0/3 {
+		t.Errorf("after reconnect: stats %+v", m.SpeedStats)
+	}
+}
@@ -0,0 +1,362 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+// storeResolution 存储中的一种分辨率，每个段文件覆盖 span 时长的数据
+type storeResolution struct {
+	name string
+	step time.Duration
+	span time.Duration
+}
+
+// 原始帧由 History 写入，1m 与 1h 汇总在压缩时由上一级生成
+var storeResolutions = []storeResolution{
+	{"raw", 0, time.Hour},
+	{"1m", time.Minute, 24 * time.Hour},
+	{"1h", time.Hour, 30 * 24 * time.Hour},
+}
+
+// Store 基于文件的指标存储，每个核心一个目录，每种分辨率一个子目录，
+// 子目录中按 span 对齐的起始时间命名段文件
+type Store struct {
+	dir       string
+	retention []time.Duration // 与 storeResolutions 一一对应
+	lock      sync.Mutex
+	series    map[string]*SeriesStore
+	now       func() time.Time
+	closed    bool
+	stop      chan struct{}  // Close 时关闭，停止 Run
+	running   sync.WaitGroup // 正在运行的 Run
+}
+
+// storeDirName 将核心名转换为安全的目录名
+func storeDirName(name string) string {
+	return strings.ReplaceAll(url.PathEscape(name), ".", "%2E")
+}
+
+func (s *Store) Core(name string) *SeriesStore {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ss, ok := s.series[name]; ok {
+		return ss
+	}
+	ss := &SeriesStore{
+		dir:       filepath.Join(s.dir, storeDirName(name)),
+		retention: s.retention,
+		active:    make([]*activeSegment, len(storeResolutions)),
+	}
+	s.series[name] = ss
+	return ss
+}
+
+// Compact 为所有核心（包括已经移除的）生成汇总数据并删除过期的段文件
+func (s *Store) Compact() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			continue
+		}
+		if err := s.Core(name).compact(now); err != nil {
+			errs = append(errs, fmt.Errorf("core %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run 每隔 interval 压缩一次，直到 ctx 取消或存储关闭，每次压缩的结果交给 onCompact
+func (s *Store) Run(ctx context.Context, interval time.Duration, onCompact func(err error)) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.running.Add(1)
+	s.lock.Unlock()
+	defer s.running.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			onCompact(s.Compact())
+		case <-ctx.Done():
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close 停止 Run 并等待正在进行的压缩结束，然后关闭所有段文件
+func (s *Store) Close() error {
+	s.lock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.lock.Unlock()
+	s.running.Wait()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var errs []error
+	for _, ss := range s.series {
+		errs = append(errs, ss.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// OpenStore 打开 sc 配置的存储目录，未配置 dir 时返回 nil
+func OpenStore(configPath string, sc runtime.StorageConfig) (*Store, error) {
+	dir := sc.Path(configPath)
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+	return &Store{
+		dir: dir,
+		retention: []time.Duration{
+			sc.Retention.RawDuration(),
+			sc.Retention.MinuteDuration(),
+			sc.Retention.HourDuration(),
+		},
+		series: make(map[string]*SeriesStore),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+type activeSegment struct {
+	start time.Time
+	file  *os.File
+}
+
+// SeriesStore 单个核心的持久化数据
+type SeriesStore struct {
+	dir       string
+	retention []time.Duration
+	lock      sync.Mutex
+	active    []*activeSegment // 各分辨率正在追加的段
+}
+
+func (ss *SeriesStore) segmentPath(resolution int, start time.Time) string {
+	return filepath.Join(ss.dir, storeResolutions[resolution].name, strconv.FormatInt(start.Unix(), 10)+".seg")
+}
+
+// segments 返回分辨率下所有段的起始时间，由旧到新
+func (ss *SeriesStore) segments(resolution int) ([]time.Time, error) {
+	entries, err := os.ReadDir(filepath.Join(ss.dir, storeResolutions[resolution].name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var starts []time.Time
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".seg")
+		if !ok {
+			continue
+		}
+		if unix, err := strconv.ParseInt(name, 10, 64); err == nil {
+			starts = append(starts, time.Unix(unix, 0))
+		}
+	}
+	slices.SortFunc(starts, time.Time.Compare)
+	return starts, nil
+}
+
+// Append 按时间将帧写入对应的段，同一批帧一次写入
+func (ss *SeriesStore) Append(resolution int, samples ...HistorySample) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	return ss.append(resolution, samples)
+}
+
+func (ss *SeriesStore) append(resolution int, samples []HistorySample) error {
+	span := storeResolutions[resolution].span
+	for len(samples) > 0 {
+		start := samples[0].Time.Truncate(span)
+		n := 1
+		for n < len(samples) && samples[n].Time.Truncate(span).Equal(start) {
+			n++
+		}
+		active := ss.active[resolution]
+		if active == nil || !active.start.Equal(start) {
+			if err := ss.closeActive(resolution); err != nil {
+				return err
+			}
+			f, err := openSegment(ss.segmentPath(resolution, start))
+			if err != nil {
+				return err
+			}
+			active = &activeSegment{start: start, file: f}
+			ss.active[resolution] = active
+		}
+		if _, err := active.file.Write(encodeRecords(samples[:n])); err != nil {
+			return err
+		}
+		samples = samples[n:]
+	}
+	return nil
+}
+
+func (ss *SeriesStore) closeActive(resolution int) error {
+	active := ss.active[resolution]
+	if active == nil {
+		return nil
+	}
+	ss.active[resolution] = nil
+	err := active.file.Sync()
+	return errors.Join(err, active.file.Close())
+}
+
+// Samples 返回分辨率下 [from, to] 内的帧，由旧到新，to 为零值时不限制结束时间
+func (ss *SeriesStore) Samples(resolution int, from, to time.Time) ([]HistorySample, error) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	return ss.samples(resolution, from, to)
+}
+
+func (ss *SeriesStore) samples(resolution int, from, to time.Time) ([]HistorySample, error) {
+	starts, err := ss.segments(resolution)
+	if err != nil {
+		return nil, err
+	}
+	span := storeResolutions[resolution].span
+	var samples []HistorySample
+	for _, start := range starts {
+		if !start.Add(span).After(from) || (!to.IsZero() && start.After(to)) {
+			continue
+		}
+		records, _, err := readSegment(ss.segmentPath(resolution, start))
+		if errors.Is(err, errCorruptSegment) {
+			// 损坏的段在下次追加时移走，读取时跳过
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, s := range records {
+			if !s.Time.Before(from) && (to.IsZero() || !s.Time.After(to)) {
+				samples = append(samples, s)
+			}
+		}
+	}
+	return samples, nil
+}
+
+// last 返回分辨率下最新一帧的时间
+func (ss *SeriesStore) last(resolution int) (time.Time, bool, error) {
+	starts, err := ss.segments(resolution)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for i := len(starts) - 1; i >= 0; i-- {
+		records, _, err := readSegment(ss.segmentPath(resolution, starts[i]))
+		if errors.Is(err, errCorruptSegment) {
+			continue
+		} else if err != nil {
+			return time.Time{}, false, err
+		}
+		if len(records) > 0 {
+			return records[len(records)-1].Time, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// resolutionFor 选择满足查询的最细分辨率：步长不小于汇总步长时使用汇总数据，
+// from 超出某一分辨率的保留时长时使用更粗的分辨率
+func (ss *SeriesStore) resolutionFor(from time.Time, step time.Duration, now time.Time) int {
+	resolution := 0
+	for i := 1; i < len(storeResolutions); i++ {
+		if step >= storeResolutions[i].step || from.Before(now.Add(-ss.retention[i-1])) {
+			resolution = i
+		}
+	}
+	return resolution
+}
+
+// compact 由上一级分辨率生成已经结束的区间的汇总，再删除过期的段。
+// 汇总从本级最新一帧之后开始，中途崩溃后重新压缩不会产生重复数据。
+func (ss *SeriesStore) compact(now time.Time) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	for resolution := 1; resolution < len(storeResolutions); resolution++ {
+		step := storeResolutions[resolution].step
+		var from time.Time
+		if last, ok, err := ss.last(resolution); err != nil {
+			return err
+		} else if ok {
+			from = last.Add(step)
+		}
+		to := now.Truncate(step).Add(-time.Nanosecond)
+		if to.Before(from) {
+			continue
+		}
+		samples, err := ss.samples(resolution-1, from, to)
+		if err != nil {
+			return err
+		}
+		if err := ss.append(resolution, downsample(samples, step)); err != nil {
+			return err
+		}
+	}
+
+	for resolution, r := range storeResolutions {
+		starts, err := ss.segments(resolution)
+		if err != nil {
+			return err
+		}
+		for _, start := range starts {
+			if start.Add(r.span).After(now.Add(-ss.retention[resolution])) {
+				break
+			}
+			if active := ss.active[resolution]; active != nil && active.start.Equal(start) {
+				if err := ss.closeActive(resolution); err != nil {
+					return err
+				}
+			}
+			if err := os.Remove(ss.segmentPath(resolution, start)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ss *SeriesStore) Close() error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	var errs []error
+	for resolution := range ss.active {
+		errs = append(errs, ss.closeActive(resolution))
+	}
+	return errors.Join(errs...)
+}
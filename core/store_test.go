@@ -0,0 +1,249 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+func newTestStore(t *testing.T, sc runtime.StorageConfig) *Store {
+	t.Helper()
+	sc.Dir = t.TempDir()
+	s, err := OpenStore("", sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStoreSurvivesRestart(t *testing.T) {
+	s := newTestStore(t, runtime.StorageConfig{})
+	base := time.Date(2025, 1, 1, 0, 58, 0, 0, time.UTC)
+	// 跨越整点，写入两个段
+	if err := s.Core("a/b").Append(0, historyAt(base, 240)...); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	reopened, err := OpenStore("", runtime.StorageConfig{Dir: s.dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, err := reopened.Core("a/b").Samples(0, base.Add(time.Minute), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 180 || got[0].TotalResult != 60 || !got[0].Time.Equal(base.Add(time.Minute)) {
+		t.Errorf("got %d samples starting with %+v", len(got), got[0])
+	}
+}
+
+func TestStoreTornWrite(t *testing.T) {
+	s := newTestStore(t, runtime.StorageConfig{})
+	base := testEpoch
+	ss := s.Core("a")
+	if err := ss.Append(0, historyAt(base, 3)...); err != nil {
+		t.Fatal(err)
+	}
+	ss.Close()
+
+	// 模拟写入最后一条记录时崩溃
+	path := ss.segmentPath(0, base)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(encodeRecords(historyAt(base.Add(3*time.Second), 1))[:recordSize/2])
+	f.Close()
+
+	if got, _ := ss.Samples(0, time.Time{}, time.Time{}); len(got) != 3 {
+		t.Fatalf("%d samples readable after torn write, want 3", len(got))
+	}
+	if err := ss.Append(0, historyAt(base.Add(4*time.Second), 1)...); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ss.Samples(0, time.Time{}, time.Time{})
+	if len(got) != 4 || !got[3].Time.Equal(base.Add(4*time.Second)) {
+		t.Errorf("samples after repair = %+v", got)
+	}
+	if info, _ := os.Stat(path); info.Size() != int64(segmentHeaderSize+4*recordSize) {
+		t.Errorf("segment size = %d", info.Size())
+	}
+}
+
+// 文件头损坏的段改名保留，之后的帧写入新的段
+func TestStoreCorruptHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{"bad magic", []byte("XXXX\x00\x00\x00\x00")},
+		{"field count", append([]byte(segmentMagic), 1, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, runtime.StorageConfig{})
+			base := testEpoch
+			ss := s.Core("a")
+			path := ss.segmentPath(0, base)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			corrupt := append(tt.header, make([]byte, recordSize)...)
+			if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := ss.Samples(0, time.Time{}, time.Time{}); err != nil || len(got) != 0 {
+				t.Errorf("samples of corrupt segment = %d, %v", len(got), err)
+			}
+			for i := range 2 {
+				if err := ss.Append(0, historyAt(base.Add(time.Duration(i)*time.Second), 1)...); err != nil {
+					t.Fatalf("append %d: %v", i, err)
+				}
+			}
+			if got, err := ss.Samples(0, time.Time{}, time.Time{}); err != nil || len(got) != 2 {
+				t.Errorf("samples after recovery = %d, %v", len(got), err)
+			}
+			if kept, err := os.ReadFile(path + ".corrupt"); err != nil || string(kept) != string(corrupt) {
+				t.Errorf("corrupt segment not kept aside: %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreCompact(t *testing.T) {
+	s := newTestStore(t, runtime.StorageConfig{Retention: runtime.StorageRetention{Raw: "2h"}})
+	base := testEpoch
+	ss := s.Core("a")
+	if err := ss.Append(0, historyAt(base, 150)...); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第三分钟尚未结束，不参与汇总；重复压缩不产生重复数据
+	s.now = func() time.Time { return base.Add(150 * time.Second) }
+	for range 2 {
+		if err := s.Compact(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	minutes, _ := ss.Samples(1, time.Time{}, time.Time{})
+	if len(minutes) != 2 || minutes[0].Speed != 29.5 || minutes[1].TotalResult != 119 {
+		t.Errorf("1m rollups = %+v", minutes)
+	}
+	if hours, _ := ss.Samples(2, time.Time{}, time.Time{}); len(hours) != 0 {
+		t.Errorf("1h rollups for an unfinished hour: %+v", hours)
+	}
+
+	// 原始数据超过保留时长后删除，汇总数据保留
+	s.now = func() time.Time { return base.Add(3*time.Hour + time.Minute) }
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(ss.dir, "raw")); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := ss.Samples(0, time.Time{}, time.Time{}); len(raw) != 0 {
+		t.Errorf("%d raw samples kept past retention", len(raw))
+	}
+	if minutes, _ := ss.Samples(1, time.Time{}, time.Time{}); len(minutes) != 3 {
+		t.Errorf("%d 1m rollups after retention, want 3", len(minutes))
+	}
+	if hours, _ := ss.Samples(2, time.Time{}, time.Time{}); len(hours) != 1 || hours[0].TotalResult != 149 {
+		t.Errorf("1h rollups = %+v", hours)
+	}
+}
+
+// Close 停止压缩循环，返回时不再有压缩在进行
+func TestStoreCloseStopsRun(t *testing.T) {
+	s := newTestStore(t, runtime.StorageConfig{})
+	compacted := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(context.Background(), time.Millisecond, func(err error) {
+			select {
+			case compacted <- struct{}{}:
+			default:
+			}
+		})
+	}()
+	<-compacted
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("Run still running after Close")
+	}
+	// 关闭后再运行立即返回
+	s.Run(context.Background(), time.Millisecond, func(err error) { t.Error("compacted after Close") })
+}
+
+func TestHistoryReadsFromStore(t *testing.T) {
+	s := newTestStore(t, runtime.StorageConfig{})
+	base := testEpoch
+	ss := s.Core("a")
+	if err := ss.Append(0, historyAt(base, 60)...); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHistory(10, time.Hour)
+	h.now = func() time.Time { return base.Add(time.Minute) }
+	h.SetStore(ss)
+	for i := 60; i < 65; i++ {
+		if err := h.Add(frameAt(i, newStatus(100, uint64(i), 0, []int{1}, []int{i}))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recent, err := h.Query(HistoryQuery{From: base.Add(61 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recent.Resolution != "memory" || len(recent.Times) != 4 {
+		t.Errorf("recent query from %s with %d points", recent.Resolution, len(recent.Times))
+	}
+	older, err := h.Query(HistoryQuery{From: base.Add(30 * time.Second), Fields: []string{"total_result"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if older.Resolution != "raw" || len(older.Times) != 35 || older.Series["total_result"][34] != 64 {
+		t.Errorf("older query from %s = %+v", older.Resolution, older.Series)
+	}
+	rollup, err := h.Query(HistoryQuery{From: base, Step: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rollup.Resolution != "1m" {
+		t.Errorf("query with a 1m step read %s", rollup.Resolution)
+	}
+}
+
+// 重启后内存中还没有数据，未指定 from 的查询从存储中读取
+func TestHistoryAfterRestart(t *testing.T) {
+	s := newTestStore(t, runtime.StorageConfig{})
+	ss := s.Core("a")
+	if err := ss.Append(0, historyAt(testEpoch, 60)...); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHistory(10, 30*time.Second)
+	h.now = func() time.Time { return testEpoch.Add(time.Minute) }
+	h.SetStore(ss)
+	series, err := h.Query(HistoryQuery{Fields: []string{"total_result"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只读取内存保留时长内的数据
+	if series.Resolution != "raw" || len(series.Times) != 30 || series.Series["total_result"][29] != 59 {
+		t.Errorf("query from %s = %+v", series.Resolution, series.Series)
+	}
+}
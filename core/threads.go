@@ -0,0 +1,131 @@
+package core
+
+import (
+	"slices"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+)
+
+type threadState struct {
+	count     uint64
+	busySince time.Time // 开始处理当前任务的时间，空闲时为零值
+}
+
+type PoolResize struct {
+	Old int `json:"old"`
+	New int `json:"new"`
+}
+
+// ThreadTracker 由 Collector 持有，重连到同一核心后继续使用，已经阻塞的线程不会重新计时
+type ThreadTracker struct {
+	threads []threadState
+	started bool
+}
+
+// Update 线程池大小变化时按线程 ID 对齐已有状态，并返回变化前后的大小
+func (tt *ThreadTracker) Update(status *monitor.Status, now time.Time) ([]*time.Time, *PoolResize) {
+	statuses := status.ThreadsDetail.ThreadsStatus
+	counts := status.ThreadsDetail.ThreadsCount
+	var resize *PoolResize
+	if len(tt.threads) != len(statuses) {
+		if tt.started {
+			resize = &PoolResize{Old: len(tt.threads), New: len(statuses)}
+		}
+		threads := make([]threadState, len(statuses))
+		copy(threads, tt.threads)
+		tt.threads = threads
+	}
+	tt.started = true
+
+	busySince := make([]*time.Time, len(statuses))
+	for tid := range statuses {
+		var count uint64
+		if tid < len(counts) {
+			count = uint64(counts[tid])
+		}
+		state := &tt.threads[tid]
+		if statuses[tid] != 1 {
+			state.busySince = time.Time{}
+		} else if state.busySince.IsZero() || state.count != count {
+			// 刚开始工作或已经换了任务
+			state.busySince = now
+		}
+		state.count = count
+		if !state.busySince.IsZero() {
+			since := state.busySince
+			busySince[tid] = &since
+		}
+	}
+	return busySince, resize
+}
+
+const (
+	hotShareFactor  = 2.0
+	coldShareFactor = 0.5
+)
+
+type ThreadStats struct {
+	Rates     []float64
+	Shares    []float64
+	Imbalance float64 // 各线程完成任务数的基尼系数，0 为完全均衡
+	Hot       []int
+	Cold      []int
+}
+
+// NewThreadStats 根据本帧与上一帧的线程任务数计算负载分布，速率与 Speed 一样按采集周期 interval 计算，prev 为 nil 时速率均为 0
+func NewThreadStats(cur, prev *monitor.Status, interval time.Duration) ThreadStats {
+	counts := cur.ThreadsDetail.ThreadsCount
+	stats := ThreadStats{
+		Rates:  make([]float64, len(counts)),
+		Shares: make([]float64, len(counts)),
+		Hot:    []int{},
+		Cold:   []int{},
+	}
+
+	var total float64
+	values := make([]float64, len(counts))
+	for tid := range counts {
+		values[tid] = float64(counts[tid])
+		total += values[tid]
+		if prev == nil || interval <= 0 {
+			continue
+		}
+		delta := uint64(counts[tid])
+		if prevCounts := prev.ThreadsDetail.ThreadsCount; tid < len(prevCounts) && counts[tid] >= prevCounts[tid] {
+			delta = uint64(counts[tid] - prevCounts[tid])
+		}
+		stats.Rates[tid] = float64(delta) / interval.Seconds()
+	}
+	if total == 0 {
+		return stats
+	}
+
+	fair := 1 / float64(len(counts))
+	for tid, v := range values {
+		share := v / total
+		stats.Shares[tid] = share
+		switch {
+		case len(counts) < 2:
+		case share > fair*hotShareFactor:
+			stats.Hot = append(stats.Hot, tid)
+		case share < fair*coldShareFactor:
+			stats.Cold = append(stats.Cold, tid)
+		}
+	}
+	stats.Imbalance = gini(values, total)
+	return stats
+}
+
+func gini(values []float64, total float64) float64 {
+	if len(values) < 2 || total == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	var weighted float64
+	for i, v := range sorted {
+		weighted += float64(2*(i+1)-len(sorted)-1) * v
+	}
+	return weighted / (float64(len(sorted)) * total)
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+)
+
+// busyOffsets 将各线程的忙碌起始时间转为相对 base 的秒数，空闲线程为 -1
+func busyOffsets(busySince []*time.Time, base time.Time) []int {
+	offsets := make([]int, len(busySince))
+	for tid, since := range busySince {
+		offsets[tid] = -1
+		if since != nil {
+			offsets[tid] = int(since.Sub(base) / time.Second)
+		}
+	}
+	return offsets
+}
+
+func TestThreadTracker(t *testing.T) {
+	base := testEpoch
+	steps := []struct {
+		name   string
+		status []int
+		counts []int
+		busy   []int
+	}{
+		{"first frame", []int{1, 0}, []int{5, 0}, []int{0, -1}},
+		{"same task keeps its start", []int{1, 1}, []int{5, 0}, []int{0, 1}},
+		{"next task restarts", []int{1, 1}, []int{6, 0}, []int{2, 1}},
+		{"idle clears", []int{0, 1}, []int{6, 0}, []int{-1, 1}},
+		{"busy again", []int{1, 1}, []int{6, 0}, []int{4, 1}},
+	}
+
+	var tracker ThreadTracker
+	for i, step := range steps {
+		busySince, resize := tracker.Update(newStatus(0, 0, 0, step.status, step.counts), base.Add(time.Duration(i)*time.Second))
+		if got := busyOffsets(busySince, base); !slices.Equal(got, step.busy) {
+			t.Errorf("%s: busy since %v, want %v", step.name, got, step.busy)
+		}
+		if resize != nil {
+			t.Errorf("%s: unexpected resize %+v", step.name, resize)
+		}
+	}
+}
+
+// 阻塞按实际经过的时间计算，重连后继续计时
+func TestThreadBlocking(t *testing.T) {
+	tests := []struct {
+		name      string
+		gaps      []time.Duration // 首帧之后每帧之前经过的时间
+		reconnect int             // 在第几帧之前重连，0 为不重连
+		want      string          // 最后一帧的问题描述，空为没有问题
+	}{
+		{"below limit", []time.Duration{time.Second, time.Second}, 0, ""},
+		{"at limit", []time.Duration{time.Second, time.Second, time.Second}, 0, "Thread 0 stuck on the same task for 3s (limit 3s)."},
+		{"wall clock, not frames", []time.Duration{4*time.Minute + 12*time.Second}, 0, "Thread 0 stuck on the same task for 4m12s (limit 3s)."},
+		{"survives reconnect", []time.Duration{time.Second, time.Second, time.Second}, 2, "Thread 0 stuck on the same task for 3s (limit 3s)."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, clock := newTestCollector(t, time.Second)
+			status := newStatus(10, 5, 0, []int{1, 0}, []int{5, 0})
+			m := c.Update(status)
+			for i, gap := range tt.gaps {
+				if i+1 == tt.reconnect {
+					c.Reset()
+				}
+				clock.advance(gap)
+				m = c.Update(status)
+			}
+
+			var got []string
+			for _, issue := range m.HealthIssues {
+				if issue.Type == "thread-blocking" && issue.ThreadID == 0 {
+					got = append(got, issue.Description)
+				}
+			}
+			if tt.want == "" && len(got) > 0 || tt.want != "" && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("issues %v, want %q", got, tt.want)
+			}
+			var total time.Duration
+			for _, gap := range tt.gaps {
+				total += gap
+			}
+			if want := uint(total / time.Second); m.ThreadsWorkingTimes[0] != want || m.ThreadsWorkingTimes[1] != 0 {
+				t.Errorf("working times %v, want [%d 0]", m.ThreadsWorkingTimes, want)
+			}
+		})
+	}
+}
+
+func TestThreadTrackerResize(t *testing.T) {
+	base := testEpoch
+	steps := []struct {
+		name   string
+		status []int
+		busy   []int
+		resize *PoolResize
+	}{
+		{"first frame is not a resize", []int{1, 1}, []int{0, 0}, nil},
+		{"grown keeps existing threads", []int{1, 1, 1, 0}, []int{0, 0, 2, -1}, &PoolResize{Old: 2, New: 4}},
+		{"shrunk to empty", nil, []int{}, &PoolResize{Old: 4, New: 0}},
+		{"regrown starts fresh", []int{1}, []int{6}, &PoolResize{Old: 0, New: 1}},
+	}
+
+	var tracker ThreadTracker
+	for i, step := range steps {
+		counts := make([]int, len(step.status))
+		now := base.Add(time.Duration(i*2) * time.Second)
+		busySince, resize := tracker.Update(newStatus(0, 0, 0, step.status, counts), now)
+		if got := busyOffsets(busySince, base); !slices.Equal(got, step.busy) {
+			t.Errorf("%s: busy since %v, want %v", step.name, got, step.busy)
+		}
+		if (resize == nil) != (step.resize == nil) || resize != nil && *resize != *step.resize {
+			t.Errorf("%s: resize %+v, want %+v", step.name, resize, step.resize)
+		}
+	}
+}
+
+// 线程池在帧之间变化时按线程 ID 对齐，不会越界
+func TestCollectorPoolResize(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []int
+		after  []int
+	}{
+		{"grown", []int{5, 5}, []int{6, 5, 3}},
+		{"shrunk", []int{5, 5, 5}, []int{7}},
+		{"emptied", []int{5, 5}, []int{}},
+		{"from empty", []int{}, []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, clock := newTestCollector(t, time.Second)
+			c.Update(newStatus(100, 10, 0, make([]int, len(tt.before)), tt.before))
+			clock.advance(time.Second)
+			m := c.Update(newStatus(100, 10, 0, make([]int, len(tt.after)), tt.after))
+
+			if len(m.ThreadsBusySince) != len(tt.after) || len(m.ThreadsWorkingTimes) != len(tt.after) {
+				t.Errorf("busy since %v, working times %v", m.ThreadsBusySince, m.ThreadsWorkingTimes)
+			}
+			var resizes []PoolResize
+			for _, event := range m.CoreEvents {
+				if event.Type == "pool_resized" {
+					resizes = append(resizes, event.Data.(PoolResize))
+				}
+			}
+			if want := (PoolResize{Old: len(tt.before), New: len(tt.after)}); len(resizes) != 1 || resizes[0] != want {
+				t.Errorf("pool_resized events %+v, want %+v", resizes, want)
+			}
+		})
+	}
+}
+
+func TestNewThreadStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev      []int // nil 为没有上一帧
+		cur       []int
+		rates     []float64
+		shares    []float64
+		imbalance float64
+		hot, cold []int
+	}{
+		{"balanced", []int{5, 5, 5, 5}, []int{10, 10, 10, 10}, []float64{2.5, 2.5, 2.5, 2.5}, []float64{.25, .25, .25, .25}, 0, []int{}, []int{}},
+		{"one thread does everything", nil, []int{40, 0, 0, 0}, []float64{0, 0, 0, 0}, []float64{1, 0, 0, 0}, 0.75, []int{0}, []int{1, 2, 3}},
+		{"single thread is never hot", []int{2}, []int{6}, []float64{2}, []float64{1}, 0, []int{}, []int{}},
+		{"no work yet", []int{0, 0}, []int{0, 0}, []float64{0, 0}, []float64{0, 0}, 0, []int{}, []int{}},
+		{"new thread counts from zero", []int{5}, []int{10, 10}, []float64{2.5, 5}, []float64{.5, .5}, 0, []int{}, []int{}},
+		{"reset counter counts from zero", []int{20}, []int{10}, []float64{5}, []float64{1}, 0, []int{}, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prev *monitor.Status
+			if tt.prev != nil {
+				prev = newStatus(0, 0, 0, make([]int, len(tt.prev)), tt.prev)
+			}
+			stats := NewThreadStats(newStatus(0, 0, 0, make([]int, len(tt.cur)), tt.cur), prev, 2*time.Second)
+			if !slices.Equal(stats.Rates, tt.rates) || !slices.Equal(stats.Shares, tt.shares) {
+				t.Errorf("rates %v, shares %v, want %v, %v", stats.Rates, stats.Shares, tt.rates, tt.shares)
+			}
+			if stats.Imbalance != tt.imbalance || !slices.Equal(stats.Hot, tt.hot) || !slices.Equal(stats.Cold, tt.cold) {
+				t.Errorf("imbalance %g, hot %v, cold %v, want %g, %v, %v", stats.Imbalance, stats.Hot, stats.Cold, tt.imbalance, tt.hot, tt.cold)
+			}
+		})
+	}
+}
+
+func TestImbalanceRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		issue  bool
+	}{
+		{"skewed", []int{40, 0, 0, 0}, true},
+		{"skewed during startup", []int{20, 0, 0, 0}, false},
+		{"balanced", []int{10, 10, 10, 10}, false},
+		{"mildly uneven", []int{14, 10, 8, 8}, false},
+		{"single thread", []int{100}, false},
+	}
+	rule := &imbalanceRule{maxImbalance: 0.5}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var total int
+			for _, n := range tt.counts {
+				total += n
+			}
+			status := newStatus(1000, uint64(total), 0, make([]int, len(tt.counts)), tt.counts)
+			stats := NewThreadStats(status, nil, time.Second)
+			m := &Metrics{Status: status, Imbalance: stats.Imbalance, HotThreads: stats.Hot, ColdThreads: stats.Cold}
+			if issues := rule.Evaluate(&RuleContext{Current: m}); (len(issues) == 1) != tt.issue || len(issues) > 1 {
+				t.Errorf("issues %+v, want issue %v (imbalance %g)", issues, tt.issue, stats.Imbalance)
+			}
+		})
+	}
+}
@@ -7,29 +7,55 @@ import (
 	"github.com/BurntSushi/toml"
 )
 
-// 凭证配置
+// 凭证配置，path 为旧配置中的 CA 证书路径。
+// type = "token" 时在每次 RPC 的 metadata 中携带令牌（token、token_env、token_file 三选一），可与 TLS 字段组合；
+// 不启用 TLS 时令牌以明文发送，必须设置 insecure = true。
 type CredentialConfig struct {
-	Path string `toml:"path"`
+	Type               string `toml:"type" json:"type"`
+	Path               string `toml:"path" json:"path"`
+	TLS                bool   `toml:"tls" json:"tls"`
+	CA                 string `toml:"ca" json:"ca"`
+	Cert               string `toml:"cert" json:"cert"`
+	Key                string `toml:"key" json:"key"`
+	ServerName         string `toml:"server_name" json:"server_name"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify" json:"insecure_skip_verify"`
+	MinVersion         string `toml:"min_version" json:"min_version"`
+	Token              string `toml:"token" json:"token" secret:"true"`
+	TokenEnv           string `toml:"token_env" json:"token_env"`
+	TokenFile          string `toml:"token_file" json:"token_file"`
+	Header             string `toml:"header" json:"header"`
+	Scheme             string `toml:"scheme" json:"scheme"`
+	Insecure           bool   `toml:"insecure" json:"insecure"`
 }
 
-type HealthCheckConfig struct {
-	MaxWorkingIntervalTimes uint	`toml:"max_working_interval_times"`
-	MinUsageRate           float32 `toml:"min_usage_rate"`
-}
-
-// 核心配置
+// 核心配置，也用于 [defaults] 与 [groups.<name>]，未设置的字段从 extends 的分组及 defaults 继承
 type CoreConfig struct {
-	Host       string `toml:"host"`
-	Port       int    `toml:"port"`
-	Interval   string `toml:"interval"`
-	Credential string `toml:"credential"`
-	HealthCheck HealthCheckConfig `toml:"health_check"`
+	Extends     string            `toml:"extends,omitempty" json:"extends,omitempty"`
+	Target      string            `toml:"target,omitempty" json:"target,omitempty"`
+	Host        string            `toml:"host,omitempty" json:"host"`
+	Port        int               `toml:"port,omitempty" json:"port"`
+	Interval    string            `toml:"interval,omitempty" json:"interval"`
+	Credential  string            `toml:"credential,omitempty" json:"credential"`
+	HealthCheck HealthCheckConfig `toml:"health_check,omitempty" json:"health_check"`
+	Reconnect   ReconnectConfig   `toml:"reconnect,omitempty" json:"reconnect"`
+	Stats       StatsConfig       `toml:"stats,omitempty" json:"stats"`
+	History     HistoryConfig     `toml:"history,omitempty" json:"history"`
+	Tags        map[string]string `toml:"tags,omitempty" json:"tags,omitempty"` // 导出指标时附加的标签
 }
 
 // 配置结构
 type Config struct {
-	Credentials map[string]CredentialConfig `toml:"credentials"`
-	Cores       map[string]CoreConfig       `toml:"cores"`
+	Credentials map[string]CredentialConfig `toml:"credentials" json:"credentials"`
+	Cores       map[string]CoreConfig       `toml:"cores" json:"cores"`
+	Defaults    CoreConfig                  `toml:"defaults" json:"defaults"`
+	Groups      map[string]CoreConfig       `toml:"groups" json:"groups"`
+	Persistence PersistenceConfig           `toml:"persistence" json:"persistence"`
+	Storage     StorageConfig               `toml:"storage" json:"storage"`
+	Prometheus  PrometheusConfig            `toml:"prometheus" json:"prometheus"`
+	Exporters   ExportersConfig             `toml:"exporters" json:"exporters"`
+
+	secrets map[string]bool // 值来自 file: 引用的配置项路径
+	defined map[string]bool // 配置文件中显式写出的键路径，以 keySep 连接
 }
 
 var (
@@ -50,9 +76,28 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 
 	var config Config
-	if _, err := toml.DecodeFile(configPath, &config); err != nil {
+	md, err := toml.DecodeFile(configPath, &config)
+	if err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
+	config.defined = definedKeys(md.Keys())
+
+	// state 模式下叠加通过 API 持久化的核心
+	if config.Persistence.Mode == PersistModeState {
+		state, err := loadState(statePath(configPath, config.Persistence))
+		if err != nil {
+			return nil, err
+		}
+		state.apply(&config)
+	}
+
+	if err := config.Interpolate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Resolve(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
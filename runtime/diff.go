@@ -0,0 +1,43 @@
+package runtime
+
+import (
+	"reflect"
+	"slices"
+)
+
+type ConfigDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+func (cd ConfigDiff) Empty() bool {
+	return len(cd.Added) == 0 && len(cd.Removed) == 0 && len(cd.Changed) == 0
+}
+
+// DiffCores 配置有变化或所用凭证出现在 changedCredentials 中的核心视为 Changed，需要重启
+func DiffCores(running, next map[string]CoreConfig, changedCredentials []string) ConfigDiff {
+	diff := ConfigDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+	for name, cc := range next {
+		oc, ok := running[name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, name)
+		case !reflect.DeepEqual(oc, cc) || slices.Contains(changedCredentials, cc.Credential):
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range running {
+		if _, ok := next[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Changed)
+	return diff
+}
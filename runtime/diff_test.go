@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffCores(t *testing.T) {
+	a := CoreConfig{Host: "a", Port: 1, Interval: "1s", Credential: "c1"}
+	b := CoreConfig{Host: "b", Port: 2, Interval: "1s", Credential: "c2"}
+	tests := []struct {
+		name        string
+		running     map[string]CoreConfig
+		next        map[string]CoreConfig
+		credentials []string
+		want        ConfigDiff
+	}{
+		{
+			name:    "unchanged",
+			running: map[string]CoreConfig{"a": a},
+			next:    map[string]CoreConfig{"a": a},
+			want:    ConfigDiff{Added: []string{}, Removed: []string{}, Changed: []string{}},
+		},
+		{
+			name:    "added and removed",
+			running: map[string]CoreConfig{"a": a},
+			next:    map[string]CoreConfig{"b": b},
+			want:    ConfigDiff{Added: []string{"b"}, Removed: []string{"a"}, Changed: []string{}},
+		},
+		{
+			name:    "changed config",
+			running: map[string]CoreConfig{"a": a},
+			next:    map[string]CoreConfig{"a": {Host: "a", Port: 1, Interval: "2s", Credential: "c1"}},
+			want:    ConfigDiff{Added: []string{}, Removed: []string{}, Changed: []string{"a"}},
+		},
+		{
+			name:        "changed credential",
+			running:     map[string]CoreConfig{"a": a, "b": b},
+			next:        map[string]CoreConfig{"a": a, "b": b},
+			credentials: []string{"c2"},
+			want:        ConfigDiff{Added: []string{}, Removed: []string{}, Changed: []string{"b"}},
+		},
+		{
+			name:    "sorted",
+			running: map[string]CoreConfig{},
+			next:    map[string]CoreConfig{"z": a, "m": b, "a": a},
+			want:    ConfigDiff{Added: []string{"a", "m", "z"}, Removed: []string{}, Changed: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffCores(tt.running, tt.next, tt.credentials)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffCores() = %+v, want %+v", got, tt.want)
+			}
+			if got.Empty() != (len(tt.want.Added)+len(tt.want.Removed)+len(tt.want.Changed) == 0) {
+				t.Errorf("Empty() = %v", got.Empty())
+			}
+		})
+	}
+}
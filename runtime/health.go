@@ -0,0 +1,201 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// 健康检查配置
+type HealthCheckConfig struct {
+	MaxWorkingIntervalTimes uint                      `toml:"max_working_interval_times,omitempty" json:"max_working_interval_times"`
+	MinUsageRate            float32                   `toml:"min_usage_rate,omitempty" json:"min_usage_rate"`
+	MaxImbalance            float64                   `toml:"max_imbalance,omitempty" json:"max_imbalance"`
+	MaxRetryRatio           float64                   `toml:"max_retry_ratio,omitempty" json:"max_retry_ratio"`               // 重试速度与结果速度之比的上限
+	RetryGrowthIntervals    uint                      `toml:"retry_growth_intervals,omitempty" json:"retry_growth_intervals"` // 重试队列连续增长多少个周期后报告
+	StaleIntervals          uint                      `toml:"stale_intervals,omitempty" json:"stale_intervals"`               // 连续多少个周期没有消息视为数据流停滞
+	Window                  string                    `toml:"window,omitempty" json:"window"`                                 // 规则可回看的历史窗口
+	DisabledRules           []string                  `toml:"disabled_rules,omitempty" json:"disabled_rules"`
+	Rules                   []HealthRuleConfig        `toml:"rules,omitempty" json:"rules"`
+	Severity                map[string]SeverityConfig `toml:"severity,omitempty" json:"severity"` // 按规则名覆盖内置规则的严重程度
+}
+
+// 严重程度配置：问题出现时为 severity，自出现起持续 warning_after / critical_after 个周期后升级
+type SeverityConfig struct {
+	Severity      string `toml:"severity,omitempty" json:"severity"`
+	WarningAfter  uint   `toml:"warning_after,omitempty" json:"warning_after"`
+	CriticalAfter uint   `toml:"critical_after,omitempty" json:"critical_after"`
+}
+
+func (sc SeverityConfig) validate(key string, errs *ValidationErrors) {
+	if sc.Severity != "" && !slices.Contains(Severities, sc.Severity) {
+		errs.add(key+".severity", "unknown severity %q, expected one of %v", sc.Severity, Severities)
+	}
+	if sc.WarningAfter > 0 && sc.CriticalAfter > 0 && sc.CriticalAfter < sc.WarningAfter {
+		errs.add(key+".critical_after", "must not be less than warning_after (%d)", sc.WarningAfter)
+	}
+}
+
+const (
+	DefaultStaleIntervals = 5
+	DefaultHealthWindow   = 5 * time.Minute
+	DefaultMaxImbalance   = 0.5
+	// DefaultMaxRetryRatio 未配置 max_retry_ratio 时，重试速度超过结果速度的一半视为重试风暴
+	DefaultMaxRetryRatio        = 0.5
+	DefaultRetryGrowthIntervals = 10
+	// DefaultMaxWorkingIntervalTimes 未配置 max_working_interval_times 时，线程处理同一个任务达到 3 个周期视为阻塞
+	DefaultMaxWorkingIntervalTimes = 3
+)
+
+func (hc HealthCheckConfig) MaxWorkingIntervalTimesOrDefault() uint {
+	if hc.MaxWorkingIntervalTimes > 0 {
+		return hc.MaxWorkingIntervalTimes
+	}
+	return DefaultMaxWorkingIntervalTimes
+}
+
+func (hc HealthCheckConfig) WindowDuration() time.Duration {
+	if d, err := time.ParseDuration(hc.Window); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHealthWindow
+}
+
+// 自定义阈值规则：metric op threshold 持续 for 之后产生健康问题
+type HealthRuleConfig struct {
+	Name      string  `toml:"name" json:"name"`
+	Metric    string  `toml:"metric" json:"metric"`
+	Op        string  `toml:"op" json:"op"`
+	Threshold float64 `toml:"threshold" json:"threshold"`
+	For       string  `toml:"for,omitempty" json:"for"`
+	Severity  string  `toml:"severity,omitempty" json:"severity"`
+	Message   string  `toml:"message,omitempty" json:"message"`
+
+	WarningAfter  uint `toml:"warning_after,omitempty" json:"warning_after"`
+	CriticalAfter uint `toml:"critical_after,omitempty" json:"critical_after"`
+}
+
+func (rc HealthRuleConfig) SeverityConfig() SeverityConfig {
+	return SeverityConfig{
+		Severity:      rc.Severity,
+		WarningAfter:  rc.WarningAfter,
+		CriticalAfter: rc.CriticalAfter,
+	}
+}
+
+func (rc HealthRuleConfig) ForDuration() time.Duration {
+	d, _ := time.ParseDuration(rc.For)
+	return d
+}
+
+var (
+	ThresholdMetrics = []string{
+		"speed", "speed_ewma", "speed_avg_1m", "speed_avg_5m", "speed_avg_15m", "speed_p50", "speed_p95",
+		"retry_delta", "retry_size", "retry_rate", "retry_ratio", "retry_queue_trend", "backlog", "usage", "working", "idle", "error_log_rate", "progress_pct", "eta",
+		"imbalance",
+	}
+	RuleOps = []string{">", ">=", "<", "<=", "==", "!="}
+	// Severities 健康问题的严重程度，由低到高
+	Severities = []string{"info", "warning", "critical"}
+)
+
+// CheckMetric 检查阈值规则的指标名，配置校验与 core.NewThresholdRule 共用
+func CheckMetric(metric string) error {
+	if !slices.Contains(ThresholdMetrics, metric) {
+		return fmt.Errorf("unknown metric %q, expected one of %v", metric, ThresholdMetrics)
+	}
+	return nil
+}
+
+func CheckOp(op string) error {
+	if !slices.Contains(RuleOps, op) {
+		return fmt.Errorf("unknown operator %q, expected one of %v", op, RuleOps)
+	}
+	return nil
+}
+
+// HealthRules 内置规则名，不依赖 core 也能校验配置
+var HealthRules = []string{
+	"thread-blocking", "no-threads-working", "low-thread-usage",
+	"core-restarted",
+	"thread-imbalance",
+	"retry-storm", "retry-queue-growth",
+}
+
+// IssueStreamStale 数据流停滞，由 Web 服务检测，不属于规则但可以配置严重程度
+const IssueStreamStale = "stream-stale"
+
+// HealthIssueTypes 不属于规则、但可以配置严重程度的问题类型
+var HealthIssueTypes = []string{IssueStreamStale}
+
+// healthRules 由 RegisterHealthRule 登记的其他规则名
+var healthRules = make(map[string]bool)
+
+// RegisterHealthRule 登记内置规则以外的规则名，使配置校验能识别它们。
+// 规则实现在 core 中，由 core.RegisterRule 在注册时调用。
+func RegisterHealthRule(name string) error {
+	if IsHealthRule(name) || slices.Contains(HealthIssueTypes, name) {
+		return fmt.Errorf("health rule %s already registered", name)
+	}
+	healthRules[name] = true
+	return nil
+}
+
+// IsHealthRule 判断 name 是否为内置或已登记的规则
+func IsHealthRule(name string) bool {
+	return slices.Contains(HealthRules, name) || healthRules[name]
+}
+
+func (hc HealthCheckConfig) validate(key string) ValidationErrors {
+	var errs ValidationErrors
+	if hc.MinUsageRate < 0 || hc.MinUsageRate > 1 {
+		errs.add(key+".min_usage_rate", "must be between 0 and 1, got %g", hc.MinUsageRate)
+	}
+	if hc.MaxImbalance < 0 || hc.MaxImbalance > 1 {
+		errs.add(key+".max_imbalance", "must be between 0 and 1, got %g", hc.MaxImbalance)
+	}
+	if hc.MaxRetryRatio < 0 {
+		errs.add(key+".max_retry_ratio", "must not be negative, got %g", hc.MaxRetryRatio)
+	}
+	if hc.Window != "" {
+		if d, err := time.ParseDuration(hc.Window); err != nil || d <= 0 {
+			errs.add(key+".window", "invalid duration %q", hc.Window)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, rc := range hc.Rules {
+		ruleKey := fmt.Sprintf("%s.rules[%d]", key, i)
+		if rc.Name == "" {
+			errs.add(ruleKey+".name", "name is required")
+		} else if names[rc.Name] {
+			errs.add(ruleKey+".name", "duplicate rule name %q", rc.Name)
+		}
+		names[rc.Name] = true
+		if err := CheckMetric(rc.Metric); err != nil {
+			errs.add(ruleKey+".metric", "%s", err)
+		}
+		if err := CheckOp(rc.Op); err != nil {
+			errs.add(ruleKey+".op", "%s", err)
+		}
+		if rc.For != "" {
+			if d, err := time.ParseDuration(rc.For); err != nil || d < 0 {
+				errs.add(ruleKey+".for", "invalid duration %q", rc.For)
+			}
+		}
+		rc.SeverityConfig().validate(ruleKey, &errs)
+	}
+	for i, name := range hc.DisabledRules {
+		if !IsHealthRule(name) {
+			errs.add(fmt.Sprintf("%s.disabled_rules[%d]", key, i), "unknown health rule %q", name)
+		}
+	}
+	for _, name := range sortedKeys(hc.Severity) {
+		severityKey := fmt.Sprintf("%s.severity.%s", key, name)
+		if !IsHealthRule(name) && !slices.Contains(HealthIssueTypes, name) {
+			errs.add(severityKey, "unknown health rule %q", name)
+		}
+		hc.Severity[name].validate(severityKey, &errs)
+	}
+	return errs
+}
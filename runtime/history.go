@@ -0,0 +1,45 @@
+package runtime
+
+import "time"
+
+// 指标历史配置，retention 与 max_samples 同时生效，先达到的限制起作用
+type HistoryConfig struct {
+	Retention  string `toml:"retention,omitempty" json:"retention"`     // 保留时长
+	MaxSamples int    `toml:"max_samples,omitempty" json:"max_samples"` // 保留的最大帧数
+}
+
+const (
+	DefaultHistoryRetention = time.Hour
+	DefaultHistorySamples   = 36000
+)
+
+func (hc HistoryConfig) RetentionDuration() time.Duration {
+	if d, err := time.ParseDuration(hc.Retention); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHistoryRetention
+}
+
+func (hc HistoryConfig) Capacity(interval time.Duration) int {
+	capacity := hc.MaxSamples
+	if capacity <= 0 {
+		capacity = DefaultHistorySamples
+	}
+	if interval > 0 {
+		capacity = min(capacity, int(hc.RetentionDuration()/interval)+1)
+	}
+	return capacity
+}
+
+func (hc HistoryConfig) validate(key string) ValidationErrors {
+	var errs ValidationErrors
+	if hc.Retention != "" {
+		if d, err := time.ParseDuration(hc.Retention); err != nil || d <= 0 {
+			errs.add(key+".retention", "invalid duration %q", hc.Retention)
+		}
+	}
+	if hc.MaxSamples < 0 {
+		errs.add(key+".max_samples", "must not be negative, got %d", hc.MaxSamples)
+	}
+	return errs
+}
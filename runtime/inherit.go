@@ -0,0 +1,179 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// keySep 连接键路径的各级键名，键名本身可能包含 "."
+const keySep = "\x00"
+
+func definedKeys(keys []toml.Key) map[string]bool {
+	defined := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		defined[strings.Join(key, keySep)] = true
+	}
+	return defined
+}
+
+func (c *Config) forgetKeys(prefix string) {
+	for key := range c.defined {
+		if key == prefix || strings.HasPrefix(key, prefix+keySep) {
+			delete(c.defined, key)
+		}
+	}
+}
+
+// layer 参与继承的一层配置，path 为其在配置文件中的键路径，为空时只按非零值判断是否设置；
+// key 为以点分隔的路径，用于追踪来自 file: 引用的敏感值
+type layer struct {
+	value reflect.Value
+	path  string
+	key   string
+}
+
+// inheritSecrets 值由 from 继承到 to 时，from 及其下来自 file: 引用的路径同样标记到 to 下
+func (c *Config) inheritSecrets(from, to string) {
+	if from == "" || to == "" || from == to {
+		return
+	}
+	for key := range c.secrets {
+		if rest, ok := strings.CutPrefix(key, from); ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+			c.secrets[to+rest] = true
+		}
+	}
+}
+
+func fieldKey(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("toml"), ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// isSet 字段非零，或在配置文件中显式写出（包括写为 0、false、""）
+func (c *Config) isSet(l layer) bool {
+	return !l.value.IsZero() || (l.path != "" && c.defined[l.path])
+}
+
+// mergeLayers 将各层按优先级由高到低合并到 dst：结构体逐字段递归合并，
+// map 逐键合并到新的 map 中，其余字段取第一个设置了该字段的层，切片复制后使用
+func (c *Config) mergeLayers(dst reflect.Value, dstKey string, layers []layer) {
+	switch dst.Kind() {
+	case reflect.Struct:
+		for i := 0; i < dst.NumField(); i++ {
+			field := dst.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := fieldKey(field)
+			sub := make([]layer, len(layers))
+			for j, l := range layers {
+				sub[j] = layer{value: l.value.Field(i)}
+				if l.path != "" {
+					sub[j].path = l.path + keySep + name
+				}
+				if l.key != "" {
+					sub[j].key = joinKey(l.key, name)
+				}
+			}
+			c.mergeLayers(dst.Field(i), joinKey(dstKey, name), sub)
+		}
+	case reflect.Map:
+		var merged reflect.Value
+		for _, l := range layers {
+			iter := l.value.MapRange()
+			for iter.Next() {
+				if !merged.IsValid() {
+					merged = reflect.MakeMapWithSize(dst.Type(), l.value.Len())
+				}
+				if merged.MapIndex(iter.Key()).IsValid() {
+					continue
+				}
+				merged.SetMapIndex(iter.Key(), iter.Value())
+				if l.key != "" {
+					name := fmt.Sprint(iter.Key())
+					c.inheritSecrets(joinKey(l.key, name), joinKey(dstKey, name))
+				}
+			}
+		}
+		if merged.IsValid() {
+			dst.Set(merged)
+		}
+	default:
+		for _, l := range layers {
+			if !c.isSet(l) {
+				continue
+			}
+			if l.value.Kind() == reflect.Slice && !l.value.IsNil() {
+				clone := reflect.MakeSlice(l.value.Type(), l.value.Len(), l.value.Len())
+				reflect.Copy(clone, l.value)
+				dst.Set(clone)
+			} else {
+				dst.Set(l.value)
+			}
+			c.inheritSecrets(l.key, dstKey)
+			return
+		}
+	}
+}
+
+// ResolveCore 计算核心的生效配置：核心自身 > extends 指定的分组链 > [defaults]。
+// 核心未设置（零值）的字段才会被继承，用于通过 API 添加的核心；
+// cc 中的环境变量与 file: 引用按加载配置文件时的规则展开。
+// 继承到的敏感值记录在 cores.<name> 下，RedactedCore 据此隐藏。
+func (c *Config) ResolveCore(name string, cc CoreConfig) (CoreConfig, error) {
+	if err := c.interpolateCore(name, &cc); err != nil {
+		return cc, err
+	}
+	return c.resolveCore(name, cc, "")
+}
+
+// resolveCore 同 ResolveCore，path 为核心在配置文件中的键路径，
+// 配置文件中显式写出的零值同样覆盖分组与 defaults 中的值
+func (c *Config) resolveCore(name string, cc CoreConfig, path string) (CoreConfig, error) {
+	key := "cores." + name
+	layers := []layer{{reflect.ValueOf(cc), path, key}}
+	var chain []string
+	for group := cc.Extends; group != ""; {
+		if slices.Contains(chain, group) {
+			return cc, fmt.Errorf("circular extends: %s -> %s", strings.Join(chain, " -> "), group)
+		}
+		chain = append(chain, group)
+		gc, ok := c.Groups[group]
+		if !ok {
+			return cc, fmt.Errorf("unknown group %q", group)
+		}
+		layers = append(layers, layer{reflect.ValueOf(gc), "groups" + keySep + group, "groups." + group})
+		group = gc.Extends
+	}
+	layers = append(layers, layer{reflect.ValueOf(c.Defaults), "defaults", "defaults"})
+
+	if c.secrets == nil {
+		c.secrets = make(map[string]bool)
+	}
+	var effective CoreConfig
+	c.mergeLayers(reflect.ValueOf(&effective).Elem(), key, layers)
+	effective.Extends = cc.Extends
+	return effective, nil
+}
+
+func (c *Config) Resolve() error {
+	var errs ValidationErrors
+	for _, name := range sortedKeys(c.Cores) {
+		effective, err := c.resolveCore(name, c.Cores[name], "cores"+keySep+name)
+		if err != nil {
+			errs.add(fmt.Sprintf("cores.%s.extends", name), "%s", err)
+			continue
+		}
+		c.Cores[name] = effective
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
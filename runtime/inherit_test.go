@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const inheritConfig = `
+[credentials.c]
+
+[defaults]
+interval = "5s"
+credential = "c"
+[defaults.health_check]
+max_working_interval_times = 3
+min_usage_rate = 0.2
+
+[groups.base]
+interval = "2s"
+[groups.base.health_check]
+min_usage_rate = 0.5
+
+[groups.child]
+extends = "base"
+host = "10.0.0.1"
+
+[cores.plain]
+host = "127.0.0.1"
+port = 9001
+
+[cores.grouped]
+extends = "child"
+port = 9002
+
+[cores.override]
+extends = "child"
+port = 9003
+interval = "1s"
+[cores.override.health_check]
+min_usage_rate = 0.0
+`
+
+func TestResolvePrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(inheritConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		core     string
+		interval string
+		host     string
+		usage    float32
+	}{
+		{"plain", "5s", "127.0.0.1", 0.2},
+		{"grouped", "2s", "10.0.0.1", 0.5},
+		// 核心显式写出的 0 覆盖分组与 defaults
+		{"override", "1s", "10.0.0.1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.core, func(t *testing.T) {
+			cc := cfg.Cores[tt.core]
+			if cc.Interval != tt.interval || cc.Host != tt.host {
+				t.Errorf("interval, host = %q, %q, want %q, %q", cc.Interval, cc.Host, tt.interval, tt.host)
+			}
+			if cc.HealthCheck.MinUsageRate != tt.usage {
+				t.Errorf("min_usage_rate = %g, want %g", cc.HealthCheck.MinUsageRate, tt.usage)
+			}
+			if cc.HealthCheck.MaxWorkingIntervalTimes != 3 || cc.Credential != "c" {
+				t.Errorf("defaults not inherited: %+v", cc)
+			}
+		})
+	}
+
+}
+
+func TestResolveCoreZeroIsUnset(t *testing.T) {
+	cfg := &Config{
+		Defaults: CoreConfig{Interval: "5s", HealthCheck: HealthCheckConfig{MinUsageRate: 0.2}},
+		Groups: map[string]CoreConfig{
+			"a": {Extends: "b"},
+			"b": {Extends: "a"},
+		},
+	}
+	// 通过 API 添加的核心没有键路径，零值视为未设置
+	cc, err := cfg.ResolveCore("api", CoreConfig{Interval: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.Interval != "1s" || cc.HealthCheck.MinUsageRate != 0.2 {
+		t.Errorf("ResolveCore() = %+v", cc)
+	}
+	if _, err := cfg.ResolveCore("api", CoreConfig{Extends: "a"}); err == nil {
+		t.Error("circular extends not detected")
+	}
+	if _, err := cfg.ResolveCore("api", CoreConfig{Extends: "missing"}); err == nil {
+		t.Error("unknown group not detected")
+	}
+}
+
+func TestResolveStateCoreExplicitZero(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	config := "[persistence]\nmode = \"state\"\n[defaults]\ninterval = \"5s\"\n[defaults.health_check]\nmin_usage_rate = 0.2\n" +
+		"[cores.a]\nhost = \"127.0.0.1\"\nport = 1\n[cores.a.health_check]\nmin_usage_rate = 0.0\n"
+	state := "revision = 1\n[cores.a]\nhost = \"127.0.0.1\"\nport = 2\n[cores.b]\nhost = \"127.0.0.1\"\nport = 3\n[cores.b.health_check]\nmin_usage_rate = 0.0\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.state.toml"), []byte(state), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 状态文件替换了 a，配置文件中 a 的显式零值不再生效
+	if got := cfg.Cores["a"].HealthCheck.MinUsageRate; got != 0.2 {
+		t.Errorf("a min_usage_rate = %g, want inherited 0.2", got)
+	}
+	if got := cfg.Cores["b"].HealthCheck.MinUsageRate; got != 0 {
+		t.Errorf("b min_usage_rate = %g, want explicit 0", got)
+	}
+}
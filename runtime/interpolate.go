@@ -0,0 +1,182 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const Redacted = "******"
+
+// walkStrings 遍历配置中所有字符串字段，key 为以点分隔的 TOML 路径，
+// secret 表示字段带有 `secret:"true"` 标签。fn 返回的值会写回原字段。
+func walkStrings(v reflect.Value, key string, secret bool, fn func(key string, secret bool, s string) (string, error)) ValidationErrors {
+	var errs ValidationErrors
+	switch v.Kind() {
+	case reflect.String:
+		s, err := fn(key, secret, v.String())
+		if err != nil {
+			errs.add(key, "%s", err)
+		} else {
+			v.SetString(s)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+			if name == "" {
+				name = field.Name
+			}
+			errs = append(errs, walkStrings(v.Field(i), joinKey(key, name), field.Tag.Get("secret") == "true", fn)...)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			errs = append(errs, walkStrings(elem, joinKey(key, fmt.Sprint(iter.Key())), secret, fn)...)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, walkStrings(v.Index(i), fmt.Sprintf("%s[%d]", key, i), secret, fn)...)
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			errs = append(errs, walkStrings(v.Elem(), key, secret, fn)...)
+		}
+	}
+	return errs
+}
+
+func joinKey(key, name string) string {
+	if key == "" {
+		return name
+	}
+	return key + "." + name
+}
+
+// expandEnv 展开 ${VAR} 与 ${VAR:-default}，$$ 表示字面量 $
+func expandEnv(s string) (string, error) {
+	var builder strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i == -1 || i == len(s)-1 {
+			builder.WriteString(s)
+			return builder.String(), nil
+		}
+		builder.WriteString(s[:i])
+		switch s[i+1] {
+		case '$':
+			builder.WriteByte('$')
+			s = s[i+2:]
+			continue
+		case '{':
+		default:
+			builder.WriteByte('$')
+			s = s[i+1:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end == -1 {
+			return "", fmt.Errorf("unterminated variable reference in %q", s)
+		}
+		expr := s[i+2 : i+end]
+		s = s[i+end+1:]
+
+		name, def, hasDefault := strings.Cut(expr, ":-")
+		if name == "" {
+			return "", fmt.Errorf("empty variable name")
+		}
+		value, ok := os.LookupEnv(name)
+		switch {
+		case ok && value != "":
+		case hasDefault:
+			value = def
+		case ok:
+		default:
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		builder.WriteString(value)
+	}
+}
+
+// resolveValue 展开环境变量，file: 前缀的值替换为对应文件的内容（视为敏感值）
+func resolveValue(s string) (string, bool, error) {
+	s, err := expandEnv(s)
+	if err != nil {
+		return "", false, err
+	}
+	path, ok := strings.CutPrefix(s, "file:")
+	if !ok {
+		return s, false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("cannot read referenced file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+func (c *Config) Interpolate() error {
+	return c.interpolate(reflect.ValueOf(c).Elem(), "")
+}
+
+// interpolateCore 展开不在配置文件中的核心配置（如通过 API 添加的核心），file: 引用的路径记录在 cores.<name> 下
+func (c *Config) interpolateCore(name string, cc *CoreConfig) error {
+	return c.interpolate(reflect.ValueOf(cc).Elem(), "cores."+name)
+}
+
+func (c *Config) interpolate(v reflect.Value, root string) error {
+	if c.secrets == nil {
+		c.secrets = make(map[string]bool)
+	}
+	errs := walkStrings(v, root, false, func(key string, secret bool, s string) (string, error) {
+		value, fromFile, err := resolveValue(s)
+		if fromFile {
+			c.secrets[key] = true
+		}
+		return value, err
+	})
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func jsonCopy[T any](v T) T {
+	var copied T
+	if content, err := json.Marshal(v); err == nil {
+		_ = json.Unmarshal(content, &copied)
+	}
+	return copied
+}
+
+func (c *Config) redact(v any, root string) {
+	walkStrings(reflect.ValueOf(v).Elem(), root, false, func(key string, secret bool, s string) (string, error) {
+		if s != "" && (secret || c.secrets[key]) {
+			return Redacted, nil
+		}
+		return s, nil
+	})
+}
+
+// Redacted 返回配置的副本，其中的敏感值（file: 引用的内容及 secret 字段）已被替换。
+// file: 引用按配置项路径记录，经 defaults 或分组继承到核心上的路径在合并时一并记录。
+func (c *Config) Redacted() *Config {
+	redacted := jsonCopy(*c)
+	c.redact(&redacted, "")
+	return &redacted
+}
+
+func (c *Config) RedactedCore(name string, cc CoreConfig) CoreConfig {
+	redacted := jsonCopy(cc)
+	c.redact(&redacted, "cores."+name)
+	return redacted
+}
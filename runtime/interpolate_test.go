@@ -0,0 +1,118 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("MT_SET", "value")
+	t.Setenv("MT_EMPTY", "")
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "plain", false},
+		{"${MT_SET}", "value", false},
+		{"a-${MT_SET}-b", "a-value-b", false},
+		{"${MT_UNSET:-fallback}", "fallback", false},
+		{"${MT_EMPTY:-fallback}", "fallback", false},
+		{"${MT_EMPTY}", "", false},
+		{"$$HOME and $PATH", "$HOME and $PATH", false},
+		{"trailing $", "trailing $", false},
+		{"${MT_UNSET}", "", true},
+		{"${MT_SET", "", true},
+		{"${}", "", true},
+	}
+	for _, tt := range tests {
+		got, err := expandEnv(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("expandEnv(%q) = %q, %v, want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRedactedTracksSecretPaths(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "token")
+	// 敏感值与其他配置项的值相同，只应隐藏来自 file: 引用的配置项
+	if err := os.WriteFile(secret, []byte("shared\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+[credentials.c]
+path = "shared"
+
+[defaults]
+interval = "1s"
+credential = "c"
+host = "file:` + secret + `"
+
+[cores.inherit]
+port = 9001
+
+[cores.override]
+host = "shared"
+port = 9002
+`
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api, err := cfg.ResolveCore("api", CoreConfig{Port: 9003})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redacted := cfg.Redacted()
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"defaults secret", redacted.Defaults.Host, Redacted},
+		{"inherited secret", redacted.Cores["inherit"].Host, Redacted},
+		{"same value, not a secret", redacted.Credentials["c"].Path, "shared"},
+		{"overridden with literal", redacted.Cores["override"].Host, "shared"},
+		{"core view", cfg.RedactedCore("inherit", cfg.Cores["inherit"]).Host, Redacted},
+		{"core view literal", cfg.RedactedCore("override", cfg.Cores["override"]).Host, "shared"},
+		{"API core", cfg.RedactedCore("api", api).Host, Redacted},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if cfg.Cores["inherit"].Host != "shared" {
+		t.Error("redaction modified the config")
+	}
+}
+
+// 通过 API 添加的核心与配置文件中的核心一样展开环境变量与 file: 引用
+func TestResolveCoreInterpolates(t *testing.T) {
+	t.Setenv("MT_INTERVAL", "2s")
+	secret := filepath.Join(t.TempDir(), "host")
+	if err := os.WriteFile(secret, []byte("10.0.0.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{}
+	cc, err := cfg.ResolveCore("api", CoreConfig{Host: "file:" + secret, Interval: "${MT_INTERVAL}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.Host != "10.0.0.1" || cc.Interval != "2s" {
+		t.Errorf("host, interval = %q, %q", cc.Host, cc.Interval)
+	}
+	if got := cfg.RedactedCore("api", cc).Host; got != Redacted {
+		t.Errorf("redacted host = %q, want %q", got, Redacted)
+	}
+	if _, err := cfg.ResolveCore("api", CoreConfig{Host: "${MT_UNSET}"}); err == nil {
+		t.Error("unset variable resolved without error")
+	}
+}
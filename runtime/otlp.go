@@ -0,0 +1,119 @@
+package runtime
+
+import (
+	"net/url"
+	"time"
+)
+
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+)
+
+// 导出器配置
+type ExportersConfig struct {
+	OTLP OTLPConfig `toml:"otlp" json:"otlp"`
+}
+
+// OTLP 导出配置，endpoint 为空时不启用。
+// grpc 协议的 endpoint 为 host:port，insecure = true 时不使用 TLS；
+// http/protobuf 协议的 endpoint 为 URL，指标与日志分别发送到 /v1/metrics 与 /v1/logs。
+// 队列满时丢弃新的数据；retry 中 max_attempts 为 0 时最多尝试 DefaultOTLPRetryAttempts 次，
+// 重试用尽后丢弃这一批数据，收集器长时间不可用时队列仍能继续发送。
+type OTLPConfig struct {
+	Endpoint      string            `toml:"endpoint" json:"endpoint"`
+	Protocol      string            `toml:"protocol" json:"protocol"`
+	Insecure      bool              `toml:"insecure" json:"insecure"`
+	Headers       map[string]string `toml:"headers" json:"headers" secret:"true"`
+	Timeout       string            `toml:"timeout" json:"timeout"`               // 单次导出请求的超时
+	BatchSize     int               `toml:"batch_size" json:"batch_size"`         // 每批最多包含的帧数与日志事件数
+	FlushInterval string            `toml:"flush_interval" json:"flush_interval"` // 批次未满时的发送周期
+	QueueSize     int               `toml:"queue_size" json:"queue_size"`         // 等待发送的最大帧数与日志事件数
+	Retry         ReconnectConfig   `toml:"retry" json:"retry"`
+}
+
+const (
+	DefaultOTLPTimeout       = 10 * time.Second
+	DefaultOTLPBatchSize     = 512
+	DefaultOTLPFlushInterval = 5 * time.Second
+	DefaultOTLPQueueSize     = 4096
+	DefaultOTLPRetryAttempts = 5
+)
+
+func (oc OTLPConfig) ProtocolOrDefault() string {
+	if oc.Protocol == "" {
+		return OTLPProtocolGRPC
+	}
+	return oc.Protocol
+}
+
+func (oc OTLPConfig) TimeoutDuration() time.Duration {
+	return positiveDuration(oc.Timeout, DefaultOTLPTimeout)
+}
+
+func (oc OTLPConfig) FlushIntervalDuration() time.Duration {
+	return positiveDuration(oc.FlushInterval, DefaultOTLPFlushInterval)
+}
+
+// RetryPolicy 导出失败时的重试策略，未设置 max_attempts 时使用 DefaultOTLPRetryAttempts
+func (oc OTLPConfig) RetryPolicy() (ReconnectPolicy, error) {
+	policy, err := oc.Retry.Policy()
+	if err != nil {
+		return policy, err
+	}
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultOTLPRetryAttempts
+	}
+	return policy, nil
+}
+
+func (oc OTLPConfig) BatchSizeOrDefault() int {
+	if oc.BatchSize <= 0 {
+		return DefaultOTLPBatchSize
+	}
+	return oc.BatchSize
+}
+
+func (oc OTLPConfig) QueueSizeOrDefault() int {
+	if oc.QueueSize <= 0 {
+		return DefaultOTLPQueueSize
+	}
+	return oc.QueueSize
+}
+
+func (oc OTLPConfig) validate(key string) ValidationErrors {
+	var errs ValidationErrors
+	if oc.Endpoint == "" {
+		return errs
+	}
+	switch oc.ProtocolOrDefault() {
+	case OTLPProtocolGRPC:
+	case OTLPProtocolHTTP:
+		if u, err := url.Parse(oc.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add(key+".endpoint", "expected an http:// or https:// URL for protocol %q, got %q", OTLPProtocolHTTP, oc.Endpoint)
+		}
+		if oc.Insecure {
+			errs.add(key+".insecure", "only applies to protocol %q, use an http:// endpoint instead", OTLPProtocolGRPC)
+		}
+	default:
+		errs.add(key+".protocol", "unknown protocol %q, expected %q or %q", oc.Protocol, OTLPProtocolGRPC, OTLPProtocolHTTP)
+	}
+	for _, field := range [][2]string{{"timeout", oc.Timeout}, {"flush_interval", oc.FlushInterval}} {
+		if field[1] == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(field[1]); err != nil || d <= 0 {
+			errs.add(key+"."+field[0], "invalid duration %q", field[1])
+		}
+	}
+	if oc.BatchSize < 0 {
+		errs.add(key+".batch_size", "must not be negative, got %d", oc.BatchSize)
+	}
+	if oc.QueueSize < 0 {
+		errs.add(key+".queue_size", "must not be negative, got %d", oc.QueueSize)
+	}
+	if _, err := oc.Retry.Policy(); err != nil {
+		errs.add(key+".retry", "%s", err)
+	}
+	return errs
+}
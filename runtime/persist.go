@@ -0,0 +1,263 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// PersistModeState 通过 API 做出的修改写入独立的状态文件，叠加在配置文件之上
+	PersistModeState = "state"
+	// PersistModeConfig 通过 API 做出的修改直接写回配置文件，只改写对应的 [cores.<name>] 表，其余内容与注释保持不变
+	PersistModeConfig = "config"
+)
+
+// 持久化配置
+type PersistenceConfig struct {
+	Mode string `toml:"mode" json:"mode"`
+	Path string `toml:"path" json:"path"`
+}
+
+type State struct {
+	Revision  uint64                `toml:"revision" json:"revision"`
+	UpdatedAt time.Time             `toml:"updated_at" json:"updated_at"`
+	Cores     map[string]CoreConfig `toml:"cores,omitempty" json:"cores"`
+	Removed   []string              `toml:"removed,omitempty" json:"removed"`
+
+	keys []toml.Key
+}
+
+func (s *State) apply(cfg *Config) {
+	if cfg.Cores == nil {
+		cfg.Cores = make(map[string]CoreConfig)
+	}
+	for _, name := range s.Removed {
+		delete(cfg.Cores, name)
+		cfg.forgetKeys("cores" + keySep + name)
+	}
+	for name, cc := range s.Cores {
+		cfg.Cores[name] = cc
+		cfg.forgetKeys("cores" + keySep + name)
+	}
+	// 状态文件中显式写出的键同样覆盖继承的值
+	if cfg.defined == nil {
+		cfg.defined = make(map[string]bool)
+	}
+	for _, key := range s.keys {
+		if len(key) > 2 && key[0] == "cores" {
+			if _, ok := s.Cores[key[1]]; ok {
+				cfg.defined[strings.Join(key, keySep)] = true
+			}
+		}
+	}
+}
+
+// statePath 状态文件路径，相对路径以配置文件所在目录为基准
+func statePath(configPath string, pc PersistenceConfig) string {
+	path := pc.Path
+	if path == "" {
+		ext := filepath.Ext(configPath)
+		path = configPath[:len(configPath)-len(ext)] + ".state.toml"
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(configPath), path)
+	}
+	return path
+}
+
+func loadState(path string) (*State, error) {
+	state := &State{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return state, nil
+	}
+	md, err := toml.DecodeFile(path, state)
+	if err != nil {
+		return nil, fmt.Errorf("解析状态文件失败: %w", err)
+	}
+	state.keys = md.Keys()
+	return state, nil
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，避免写入中途崩溃损坏原文件
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		_ = os.Chmod(tmp.Name(), info.Mode())
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Persister 将通过 API 增删的核心持久化，所有写入串行执行
+type Persister struct {
+	mode       string
+	configPath string
+	statePath  string
+	lock       sync.Mutex
+}
+
+func (p *Persister) Mode() string {
+	return p.mode
+}
+
+func (p *Persister) State() (*State, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return loadState(p.statePath)
+}
+
+func (p *Persister) PutCore(name string, cc CoreConfig) (uint64, error) {
+	if p.mode == PersistModeConfig {
+		table, err := coreTable(cc)
+		if err != nil {
+			return 0, err
+		}
+		return p.update(nil, func(content []byte) ([]byte, error) {
+			return editCoreTable(content, name, table)
+		})
+	}
+	return p.update(func(state *State) error {
+		if state.Cores == nil {
+			state.Cores = make(map[string]CoreConfig)
+		}
+		state.Cores[name] = cc
+		state.Removed = slices.DeleteFunc(state.Removed, func(n string) bool { return n == name })
+		return nil
+	}, nil)
+}
+
+func (p *Persister) DeleteCore(name string) (uint64, error) {
+	if p.mode == PersistModeConfig {
+		return p.update(nil, func(content []byte) ([]byte, error) {
+			return editCoreTable(content, name, nil)
+		})
+	}
+	return p.update(func(state *State) error {
+		delete(state.Cores, name)
+		var base Config
+		if _, err := toml.DecodeFile(p.configPath, &base); err != nil {
+			return fmt.Errorf("解析配置文件失败: %w", err)
+		}
+		if _, ok := base.Cores[name]; ok && !slices.Contains(state.Removed, name) {
+			state.Removed = append(state.Removed, name)
+		}
+		return nil
+	}, nil)
+}
+
+// update editConfig 不为空时（config 模式）以配置文件原文为输入，返回修改后的内容。
+// 状态文件写入失败时恢复配置文件原文，不留下部分生效的修改。
+func (p *Persister) update(modify func(state *State) error, editConfig func(content []byte) ([]byte, error)) (uint64, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	state, err := loadState(p.statePath)
+	if err != nil {
+		return 0, err
+	}
+
+	var original, edited []byte
+	if editConfig != nil {
+		if original, err = os.ReadFile(p.configPath); err != nil {
+			return 0, fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		if edited, err = editConfig(original); err != nil {
+			return 0, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	}
+	if modify != nil {
+		if err = modify(state); err != nil {
+			return 0, err
+		}
+	}
+
+	state.Revision++
+	state.UpdatedAt = time.Now()
+	content, err := encodeTOML(state)
+	if err != nil {
+		return 0, err
+	}
+
+	if editConfig != nil {
+		if err = writeFileAtomic(p.configPath, edited); err != nil {
+			return 0, fmt.Errorf("写入配置文件失败: %w", err)
+		}
+	}
+	if err = writeFileAtomic(p.statePath, content); err != nil {
+		if editConfig != nil {
+			if restoreErr := writeFileAtomic(p.configPath, original); restoreErr != nil {
+				return 0, fmt.Errorf("写入状态文件失败: %w，恢复配置文件失败: %v", err, restoreErr)
+			}
+		}
+		return 0, fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	return state.Revision, nil
+}
+
+func encodeTOML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// coreTable 将核心配置转换为只包含已设置字段的 TOML 表
+func coreTable(cc CoreConfig) (map[string]any, error) {
+	table, err := toTable(cc)
+	if err != nil {
+		return nil, err
+	}
+	return pruneTable(table), nil
+}
+
+func toTable(v any) (map[string]any, error) {
+	content, err := encodeTOML(v)
+	if err != nil {
+		return nil, err
+	}
+	table := make(map[string]any)
+	if _, err := toml.Decode(string(content), &table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// NewPersister 根据持久化配置创建 Persister，未启用持久化时返回 nil
+func NewPersister(configPath string, pc PersistenceConfig) (*Persister, error) {
+	switch pc.Mode {
+	case "":
+		return nil, nil
+	case PersistModeState, PersistModeConfig:
+	default:
+		return nil, fmt.Errorf("未知的持久化模式: %s", pc.Mode)
+	}
+	if configPath == "" {
+		configPath = "config.toml"
+	}
+	return &Persister{
+		mode:       pc.Mode,
+		configPath: configPath,
+		statePath:  statePath(configPath, pc),
+	}, nil
+}
@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const persistConfig = `# monitor config
+[credentials.c]
+
+# the main core
+[cores.a]
+host = "a" # inline comment
+port = 1
+interval = "1s"
+credential = "c"
+
+[cores.a.health_check]
+max_working_interval_times = 3
+
+# second core
+[cores."b.x"]
+host = "b"
+port = 2
+interval = "1s"
+credential = "c"
+
+[persistence]
+mode = "config"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestPersisterConfigModeKeepsComments(t *testing.T) {
+	path := writeConfig(t, persistConfig)
+	p, err := NewPersister(path, PersistenceConfig{Mode: PersistModeConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev, err := p.PutCore("new", CoreConfig{Host: "n", Port: 3, Interval: "1s", Credential: "c"}); err != nil || rev != 1 {
+		t.Fatalf("PutCore() = %d, %v", rev, err)
+	}
+	content := readFile(t, path)
+	for _, want := range []string{"# monitor config", "# the main core", "# inline comment", "# second core", "[cores.new]"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("config lost %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "max_retry_ratio") {
+		t.Errorf("unset fields were written:\n%s", content)
+	}
+	if !strings.HasPrefix(content, persistConfig) {
+		t.Errorf("existing content changed:\n%s", content)
+	}
+
+	if rev, err := p.DeleteCore("a"); err != nil || rev != 2 {
+		t.Fatalf("DeleteCore() = %d, %v", rev, err)
+	}
+	content = readFile(t, path)
+	for _, gone := range []string{"[cores.a]", "[cores.a.health_check]", "# the main core", "# inline comment"} {
+		if strings.Contains(content, gone) {
+			t.Errorf("deleted core left %q:\n%s", gone, content)
+		}
+	}
+	for _, want := range []string{"# monitor config", "# second core", "[persistence]"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("config lost %q:\n%s", want, content)
+		}
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Cores) != 2 || cfg.Cores["new"].Host != "n" || cfg.Cores["b.x"].Host != "b" {
+		t.Errorf("cores after edits = %+v", cfg.Cores)
+	}
+}
+
+func TestPersisterConfigModeReplacesCore(t *testing.T) {
+	path := writeConfig(t, persistConfig)
+	p, _ := NewPersister(path, PersistenceConfig{Mode: PersistModeConfig})
+	if _, err := p.PutCore("b.x", CoreConfig{Host: "c", Port: 9003, Interval: "2s", Credential: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	content := readFile(t, path)
+	if !strings.Contains(content, "# second core\n[cores.\"b.x\"]\n") || strings.Contains(content, `host = "b"`) {
+		t.Errorf("core not replaced in place:\n%s", content)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Cores["b.x"]; got.Host != "c" || got.Port != 9003 {
+		t.Errorf("replaced core = %+v", got)
+	}
+	if got := cfg.Cores["a"].HealthCheck.MaxWorkingIntervalTimes; got != 3 {
+		t.Errorf("other core changed, max_working_interval_times = %d", got)
+	}
+}
+
+func TestPersisterConfigModeInlineTableFallback(t *testing.T) {
+	path := writeConfig(t, "# comment\n[cores]\na = { host = \"a\", port = 1 }\n")
+	p, _ := NewPersister(path, PersistenceConfig{Mode: PersistModeConfig})
+	if _, err := p.DeleteCore("a"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Cores) != 0 {
+		t.Errorf("inline core not deleted: %+v", cfg.Cores)
+	}
+}
+
+func TestPersisterStateMode(t *testing.T) {
+	path := writeConfig(t, strings.Replace(persistConfig, `mode = "config"`, `mode = "state"`, 1))
+	p, _ := NewPersister(path, PersistenceConfig{Mode: PersistModeState})
+	if _, err := p.PutCore("new", CoreConfig{Host: "n", Port: 3, Interval: "1s", Credential: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if rev, err := p.DeleteCore("a"); err != nil || rev != 2 {
+		t.Fatalf("DeleteCore() = %d, %v", rev, err)
+	}
+	if readFile(t, path) != strings.Replace(persistConfig, `mode = "config"`, `mode = "state"`, 1) {
+		t.Error("state mode modified the config file")
+	}
+	state, err := p.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.Removed, []string{"a"}) || state.Revision != 2 {
+		t.Errorf("state = %+v", state)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Cores["a"]; ok || cfg.Cores["new"].Host != "n" || len(cfg.Cores) != 2 {
+		t.Errorf("cores with state applied = %+v", cfg.Cores)
+	}
+
+	// 重新添加被删除的核心后不再出现在 removed 中
+	if _, err := p.PutCore("a", CoreConfig{Host: "a2", Port: 1, Interval: "1s", Credential: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := p.State(); len(state.Removed) != 0 {
+		t.Errorf("removed = %v after re-adding", state.Removed)
+	}
+}
+
+func TestPersisterMissingConfig(t *testing.T) {
+	p, _ := NewPersister(filepath.Join(t.TempDir(), "missing.toml"), PersistenceConfig{Mode: PersistModeConfig})
+	if _, err := p.DeleteCore("a"); err == nil {
+		t.Error("DeleteCore() succeeded without a config file")
+	}
+}
+
+// 状态文件写入失败时配置文件保持原样
+func TestPersisterConfigModeRestoresOnStateFailure(t *testing.T) {
+	path := writeConfig(t, persistConfig)
+	p, err := NewPersister(path, PersistenceConfig{Mode: PersistModeConfig, Path: "missing/state.toml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.PutCore("new", CoreConfig{Host: "n", Port: 3, Interval: "1s", Credential: "c"}); err == nil {
+		t.Fatal("PutCore() succeeded without a writable state file")
+	}
+	if _, err := p.DeleteCore("a"); err == nil {
+		t.Fatal("DeleteCore() succeeded without a writable state file")
+	}
+	if content := readFile(t, path); content != persistConfig {
+		t.Errorf("config changed after a failed update:\n%s", content)
+	}
+}
+
+func TestTomlTableHeader(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		ok   bool
+	}{
+		{"[cores.a]", []string{"cores", "a"}, true},
+		{"  [ cores . \"a.b\" . health_check ]  # comment", []string{"cores", "a.b", "health_check"}, true},
+		{"[cores.'lit.eral']", []string{"cores", "lit.eral"}, true},
+		{"[[cores.a.rules]]", []string{"cores", "a", "rules"}, true},
+		{"[cores.a] x", nil, false},
+		{"host = \"[cores.a]\"", nil, false},
+		{"[cores.\"open]", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := tomlTableHeader(tt.line)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("tomlTableHeader(%q) = %q, %v, want %q, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSpliceCoreTableSkipsMultilineStrings(t *testing.T) {
+	content := "[cores.a]\nhost = \"a\"\nnote = \"\"\"\n[cores.b]\n\"\"\"\n\n[cores.b]\nhost = \"b\"\n"
+	got := spliceCoreTable(content, "b", "")
+	if got != "[cores.a]\nhost = \"a\"\nnote = \"\"\"\n[cores.b]\n\"\"\"\n\n" {
+		t.Errorf("spliceCoreTable() = %q", got)
+	}
+}
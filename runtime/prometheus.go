@@ -0,0 +1,30 @@
+package runtime
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Prometheus 导出配置，per_thread 开启后按线程导出指标，序列数随线程数增长
+type PrometheusConfig struct {
+	PerThread bool `toml:"per_thread" json:"per_thread"`
+}
+
+var tagNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ReservedTags 导出指标时已经使用的标签名，不能作为核心的标签
+var ReservedTags = []string{"core", "thread", "state", "severity"}
+
+func validateTags(key string, tags map[string]string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range sortedKeys(tags) {
+		switch {
+		case !tagNamePattern.MatchString(name) || strings.HasPrefix(name, "__"):
+			errs.add(key+"."+name, "invalid tag name, expected [a-zA-Z_][a-zA-Z0-9_]* without a leading __")
+		case slices.Contains(ReservedTags, name):
+			errs.add(key+"."+name, "tag name is reserved, used by %v", ReservedTags)
+		}
+	}
+	return errs
+}
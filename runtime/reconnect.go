@@ -0,0 +1,82 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// 重连策略配置，未设置的字段使用 DefaultReconnectPolicy 中的值
+type ReconnectConfig struct {
+	InitialDelay string   `toml:"initial_delay,omitempty" json:"initial_delay"`
+	Multiplier   float64  `toml:"multiplier,omitempty" json:"multiplier"`
+	MaxDelay     string   `toml:"max_delay,omitempty" json:"max_delay"`
+	Jitter       *float64 `toml:"jitter,omitempty" json:"jitter,omitempty"` // 未设置时使用默认值，0 表示不抖动
+	MaxAttempts  uint     `toml:"max_attempts,omitempty" json:"max_attempts"`
+}
+
+type ReconnectPolicy struct {
+	InitialDelay time.Duration
+	Multiplier   float64
+	MaxDelay     time.Duration
+	Jitter       float64 // 随机抖动比例，0.2 表示在 ±20% 范围内浮动
+	MaxAttempts  uint    // 连续失败达到该次数后进入 failed 状态，0 表示不限
+}
+
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialDelay: time.Second,
+	Multiplier:   2,
+	MaxDelay:     time.Minute,
+	Jitter:       0.2,
+}
+
+// Delay 返回第 attempt 次（从 1 开始）失败后的等待时间
+func (rp ReconnectPolicy) Delay(attempt uint) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := float64(rp.InitialDelay) * math.Pow(rp.Multiplier, float64(attempt-1))
+	if delay > float64(rp.MaxDelay) {
+		delay = float64(rp.MaxDelay)
+	}
+	if rp.Jitter > 0 {
+		delay *= 1 + rp.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+func (rc ReconnectConfig) Policy() (ReconnectPolicy, error) {
+	policy := DefaultReconnectPolicy
+	if rc.InitialDelay != "" {
+		d, err := time.ParseDuration(rc.InitialDelay)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid initial_delay %q", rc.InitialDelay)
+		}
+		policy.InitialDelay = d
+	}
+	if rc.MaxDelay != "" {
+		d, err := time.ParseDuration(rc.MaxDelay)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid max_delay %q", rc.MaxDelay)
+		}
+		policy.MaxDelay = d
+	}
+	if rc.Multiplier != 0 {
+		if rc.Multiplier < 1 {
+			return policy, fmt.Errorf("multiplier must be at least 1, got %g", rc.Multiplier)
+		}
+		policy.Multiplier = rc.Multiplier
+	}
+	if rc.Jitter != nil {
+		if *rc.Jitter < 0 || *rc.Jitter > 1 {
+			return policy, fmt.Errorf("jitter must be between 0 and 1, got %g", *rc.Jitter)
+		}
+		policy.Jitter = *rc.Jitter
+	}
+	if policy.InitialDelay > policy.MaxDelay {
+		return policy, fmt.Errorf("initial_delay %s exceeds max_delay %s", policy.InitialDelay, policy.MaxDelay)
+	}
+	policy.MaxAttempts = rc.MaxAttempts
+	return policy, nil
+}
@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+func jitter(v float64) *float64 {
+	return &v
+}
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	policy := ReconnectPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		attempt uint
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+
+	policy.Jitter = 0.2
+	for range 100 {
+		if got := policy.Delay(2); got < 1600*time.Millisecond || got > 2400*time.Millisecond {
+			t.Fatalf("Delay(2) with jitter = %s, want within ±20%% of 2s", got)
+		}
+	}
+}
+
+func TestReconnectConfigPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ReconnectConfig
+		want    ReconnectPolicy
+		wantErr bool
+	}{
+		{"defaults", ReconnectConfig{}, DefaultReconnectPolicy, false},
+		{"custom", ReconnectConfig{InitialDelay: "10ms", MaxDelay: "1s", Multiplier: 3, Jitter: jitter(0.5), MaxAttempts: 4},
+			ReconnectPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: time.Second, Multiplier: 3, Jitter: 0.5, MaxAttempts: 4}, false},
+		{"bad initial delay", ReconnectConfig{InitialDelay: "soon"}, ReconnectPolicy{}, true},
+		{"multiplier below 1", ReconnectConfig{Multiplier: 0.5}, ReconnectPolicy{}, true},
+		{"jitter disabled", ReconnectConfig{Jitter: jitter(0)}, ReconnectPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: time.Minute}, false},
+		{"jitter above 1", ReconnectConfig{Jitter: jitter(2)}, ReconnectPolicy{}, true},
+		{"initial above max", ReconnectConfig{InitialDelay: "2m"}, ReconnectPolicy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.config.Policy()
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("%s: Policy() = %+v, %v", tt.name, got, err)
+		}
+	}
+}
+
+// 配置文件中显式写出 jitter = 0 时关闭抖动，退避时间固定
+func TestReconnectConfigJitterZero(t *testing.T) {
+	var rc ReconnectConfig
+	if _, err := toml.Decode("initial_delay = \"1s\"\njitter = 0\n", &rc); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := rc.Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		if got := policy.Delay(2); got != 2*time.Second {
+			t.Fatalf("Delay(2) = %s, want exactly 2s without jitter", got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package runtime
+
+import "time"
+
+// 吞吐统计配置
+type StatsConfig struct {
+	Window string `toml:"window,omitempty" json:"window"` // 计算 min/max/p50/p95 的窗口
+}
+
+const DefaultStatsWindow = 5 * time.Minute
+
+func (sc StatsConfig) WindowDuration() time.Duration {
+	if d, err := time.ParseDuration(sc.Window); err == nil && d > 0 {
+		return d
+	}
+	return DefaultStatsWindow
+}
+
+func (sc StatsConfig) validate(key string) ValidationErrors {
+	var errs ValidationErrors
+	if sc.Window != "" {
+		if d, err := time.ParseDuration(sc.Window); err != nil || d <= 0 {
+			errs.add(key+".window", "invalid duration %q", sc.Window)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// 指标持久化存储配置，dir 为空时不启用，相对路径以配置文件所在目录为基准
+type StorageConfig struct {
+	Dir             string           `toml:"dir" json:"dir"`
+	CompactInterval string           `toml:"compact_interval" json:"compact_interval"` // 汇总与清理过期数据的周期
+	Retention       StorageRetention `toml:"retention" json:"retention"`
+}
+
+// 各分辨率数据的保留时长
+type StorageRetention struct {
+	Raw    string `toml:"raw" json:"raw"`
+	Minute string `toml:"1m" json:"1m"`
+	Hour   string `toml:"1h" json:"1h"`
+}
+
+const (
+	DefaultCompactInterval = 5 * time.Minute
+	DefaultRawRetention    = 24 * time.Hour
+	DefaultMinuteRetention = 7 * 24 * time.Hour
+	DefaultHourRetention   = 365 * 24 * time.Hour
+)
+
+func positiveDuration(value string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+func (sc StorageConfig) Path(configPath string) string {
+	if sc.Dir == "" || filepath.IsAbs(sc.Dir) {
+		return sc.Dir
+	}
+	return filepath.Join(filepath.Dir(configPath), sc.Dir)
+}
+
+func (sc StorageConfig) CompactIntervalDuration() time.Duration {
+	return positiveDuration(sc.CompactInterval, DefaultCompactInterval)
+}
+
+func (sr StorageRetention) RawDuration() time.Duration {
+	return positiveDuration(sr.Raw, DefaultRawRetention)
+}
+
+func (sr StorageRetention) MinuteDuration() time.Duration {
+	return positiveDuration(sr.Minute, DefaultMinuteRetention)
+}
+
+func (sr StorageRetention) HourDuration() time.Duration {
+	return positiveDuration(sr.Hour, DefaultHourRetention)
+}
+
+func (sc StorageConfig) validate(key string) ValidationErrors {
+	var errs ValidationErrors
+	for _, field := range [][2]string{
+		{"compact_interval", sc.CompactInterval},
+		{"retention.raw", sc.Retention.Raw},
+		{"retention.1m", sc.Retention.Minute},
+		{"retention.1h", sc.Retention.Hour},
+	} {
+		if field[1] == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(field[1]); err != nil || d <= 0 {
+			errs.add(key+"."+field[0], "invalid duration %q", field[1])
+		}
+	}
+	return errs
+}
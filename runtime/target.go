@@ -0,0 +1,53 @@
+package runtime
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var targetSchemes = []string{"unix", "unix-abstract", "dns", "passthrough"}
+
+// Address 返回规范化后的 gRPC 目标地址。
+// 优先使用 target（unix:///run/x.sock、dns:///svc:port、[::1]:9000 等），
+// 未设置时由 host/port 组成，IPv6 字面量会自动加上方括号。
+func (cc CoreConfig) Address() (string, error) {
+	if cc.Target == "" {
+		if cc.Host == "" {
+			return "", fmt.Errorf("either target or host is required")
+		}
+		if cc.Port < 1 || cc.Port > 65535 {
+			return "", fmt.Errorf("port %d out of range 1-65535", cc.Port)
+		}
+		return net.JoinHostPort(strings.Trim(cc.Host, "[]"), strconv.Itoa(cc.Port)), nil
+	}
+
+	target := cc.Target
+	// 绝对路径视为 unix 套接字
+	if strings.HasPrefix(target, "/") {
+		return "unix://" + target, nil
+	}
+
+	if scheme, rest, ok := strings.Cut(target, ":"); ok && (strings.HasPrefix(rest, "//") || scheme == "unix" || scheme == "unix-abstract") {
+		for _, s := range targetSchemes {
+			if scheme == s {
+				return target, nil
+			}
+		}
+		return "", fmt.Errorf("unsupported target scheme %q", scheme)
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		// 没有端口时使用 port 字段
+		if cc.Port == 0 {
+			return "", fmt.Errorf("invalid target %q: %w", target, err)
+		}
+		host, port = strings.Trim(target, "[]"), strconv.Itoa(cc.Port)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return "", fmt.Errorf("invalid port %q in target", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
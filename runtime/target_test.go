@@ -0,0 +1,43 @@
+package runtime
+
+import "testing"
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		cc   CoreConfig
+		want string
+		err  bool
+	}{
+		{"host and port", CoreConfig{Host: "127.0.0.1", Port: 9000}, "127.0.0.1:9000", false},
+		{"ipv6 host", CoreConfig{Host: "::1", Port: 9000}, "[::1]:9000", false},
+		{"bracketed ipv6 host", CoreConfig{Host: "[::1]", Port: 9000}, "[::1]:9000", false},
+		{"missing host", CoreConfig{Port: 9000}, "", true},
+		{"port out of range", CoreConfig{Host: "localhost", Port: 70000}, "", true},
+		{"host:port target", CoreConfig{Target: "core.internal:9000"}, "core.internal:9000", false},
+		{"ipv6 target", CoreConfig{Target: "[::1]:9000"}, "[::1]:9000", false},
+		{"target without port uses port", CoreConfig{Target: "core.internal", Port: 9000}, "core.internal:9000", false},
+		{"bare ipv6 target uses port", CoreConfig{Target: "[::1]", Port: 9000}, "[::1]:9000", false},
+		{"target without any port", CoreConfig{Target: "core.internal"}, "", true},
+		{"target overrides host", CoreConfig{Target: "a:1", Host: "b", Port: 2}, "a:1", false},
+		{"bad target port", CoreConfig{Target: "core.internal:http"}, "", true},
+		{"absolute path", CoreConfig{Target: "/run/core.sock"}, "unix:///run/core.sock", false},
+		{"unix scheme", CoreConfig{Target: "unix:///run/core.sock"}, "unix:///run/core.sock", false},
+		{"relative unix", CoreConfig{Target: "unix:core.sock"}, "unix:core.sock", false},
+		{"abstract unix", CoreConfig{Target: "unix-abstract:core"}, "unix-abstract:core", false},
+		{"dns", CoreConfig{Target: "dns:///core.internal:9000"}, "dns:///core.internal:9000", false},
+		{"passthrough", CoreConfig{Target: "passthrough:///core:9000"}, "passthrough:///core:9000", false},
+		{"unsupported scheme", CoreConfig{Target: "http://core:9000"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cc.Address()
+			if (err != nil) != tt.err {
+				t.Fatalf("Address() error = %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Address() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
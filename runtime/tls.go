@@ -0,0 +1,106 @@
+package runtime
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// caFile 返回 CA 证书路径，兼容旧的 path 配置
+func (cc CredentialConfig) caFile() string {
+	if cc.CA != "" {
+		return cc.CA
+	}
+	return cc.Path
+}
+
+func (cc CredentialConfig) TLSEnabled() bool {
+	return cc.Type == CredentialTLS || cc.TLS || cc.caFile() != "" || cc.Cert != "" || cc.Key != "" ||
+		cc.ServerName != "" || cc.InsecureSkipVerify || cc.MinVersion != ""
+}
+
+func (cc CredentialConfig) TLSConfig() (*tls.Config, error) {
+	if !cc.TLSEnabled() {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		ServerName:         cc.ServerName,
+		InsecureSkipVerify: cc.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	// 旧配置只有 path 时沿用原先固定的服务名
+	if config.ServerName == "" && cc.CA == "" && cc.Path != "" {
+		config.ServerName = "localhost"
+	}
+
+	if cc.MinVersion != "" {
+		version, ok := tlsVersions[cc.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q", cc.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if ca := cc.caFile(); ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", ca)
+		}
+		config.RootCAs = pool
+	}
+
+	if cc.Cert != "" || cc.Key != "" {
+		if cc.Cert == "" || cc.Key == "" {
+			return nil, fmt.Errorf("client certificate requires both cert and key")
+		}
+		pair, err := tls.LoadX509KeyPair(cc.Cert, cc.Key)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	return config, nil
+}
+
+func (cc CredentialConfig) TransportCredentials() (credentials.TransportCredentials, error) {
+	config, err := cc.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return insecure.NewCredentials(), nil
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// DialOptions 返回连接核心所需的 gRPC 选项，Web 监控与终端监控共用
+func (cc CredentialConfig) DialOptions() ([]grpc.DialOption, error) {
+	creds, err := cc.TransportCredentials()
+	if err != nil {
+		return nil, err
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if perRPC := cc.PerRPCCredentials(); perRPC != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(perRPC))
+	}
+	return opts, nil
+}
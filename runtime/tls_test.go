@@ -0,0 +1,215 @@
+package runtime
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA 测试用的 CA，签发的证书写入 dir
+type testCA struct {
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	ca := &testCA{dir: t.TempDir()}
+	ca.cert, ca.key = ca.issue(t, "ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	})
+	return ca
+}
+
+// issue 签发证书并写入 <name>.pem 与 <name>-key.pem，CA 尚未创建时自签名
+func (ca *testCA) issue(t *testing.T, name string, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.write(t, name+".pem", "CERTIFICATE", der)
+	ca.write(t, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+	return cert, key
+}
+
+func (ca *testCA) write(t *testing.T, name, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(ca.path(name), pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+func TestTLSConfig(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue(t, "client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err := os.WriteFile(ca.path("empty.pem"), []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		cc         CredentialConfig
+		enabled    bool
+		serverName string
+		minVersion uint16
+		rootCAs    bool
+		certs      int
+		err        bool
+	}{
+		{"plaintext", CredentialConfig{}, false, "", 0, false, 0, false},
+		{"tls with system roots", CredentialConfig{TLS: true}, true, "", tls.VersionTLS12, false, 0, false},
+		{"legacy path", CredentialConfig{Path: ca.path("ca.pem")}, true, "localhost", tls.VersionTLS12, true, 0, false},
+		{"ca and server name", CredentialConfig{CA: ca.path("ca.pem"), ServerName: "mt.test"}, true, "mt.test", tls.VersionTLS12, true, 0, false},
+		{"min version", CredentialConfig{MinVersion: "1.3"}, true, "", tls.VersionTLS13, false, 0, false},
+		{"mutual tls", CredentialConfig{CA: ca.path("ca.pem"), Cert: ca.path("client.pem"), Key: ca.path("client-key.pem")}, true, "", tls.VersionTLS12, true, 1, false},
+		{"cert without key", CredentialConfig{Cert: ca.path("client.pem")}, true, "", 0, false, 0, true},
+		{"missing ca", CredentialConfig{CA: ca.path("missing.pem")}, true, "", 0, false, 0, true},
+		{"ca without certificates", CredentialConfig{CA: ca.path("empty.pem")}, true, "", 0, false, 0, true},
+		{"mismatched key", CredentialConfig{Cert: ca.path("client.pem"), Key: ca.path("ca-key.pem")}, true, "", 0, false, 0, true},
+		{"unsupported version", CredentialConfig{MinVersion: "2.0"}, true, "", 0, false, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cc.TLSEnabled(); got != tt.enabled {
+				t.Errorf("TLSEnabled() = %v, want %v", got, tt.enabled)
+			}
+			config, err := tt.cc.TLSConfig()
+			if (err != nil) != tt.err {
+				t.Fatalf("TLSConfig() error = %v, want error %v", err, tt.err)
+			}
+			if err != nil || !tt.enabled {
+				if config != nil {
+					t.Errorf("TLSConfig() = %+v, want nil", config)
+				}
+				return
+			}
+			if config.ServerName != tt.serverName || config.MinVersion != tt.minVersion ||
+				(config.RootCAs != nil) != tt.rootCAs || len(config.Certificates) != tt.certs {
+				t.Errorf("TLSConfig() server name %q, min version %x, root CAs %v, %d certificates",
+					config.ServerName, config.MinVersion, config.RootCAs != nil, len(config.Certificates))
+			}
+		})
+	}
+}
+
+// 按凭证构建的配置能与要求客户端证书的服务端完成握手，服务名不符时失败
+func TestTLSConfigHandshake(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue(t, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		DNSNames:    []string{"mt.test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	ca.issue(t, "client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	serverPair, err := tls.LoadX509KeyPair(ca.path("server.pem"), ca.path("server-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	tests := []struct {
+		name       string
+		serverName string
+		clientCert bool
+		ok         bool
+	}{
+		{"mutual tls", "mt.test", true, true},
+		{"wrong server name", "other.test", true, false},
+		{"no client certificate", "mt.test", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := CredentialConfig{CA: ca.path("ca.pem"), ServerName: tt.serverName}
+			if tt.clientCert {
+				cc.Cert, cc.Key = ca.path("client.pem"), ca.path("client-key.pem")
+			}
+			config, err := cc.TLSConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+				Certificates: []tls.Certificate{serverPair},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    clientCAs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			serverErr := make(chan error, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					serverErr <- err
+					return
+				}
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				serverErr <- conn.(*tls.Conn).Handshake()
+			}()
+
+			dialer := &net.Dialer{Timeout: 5 * time.Second}
+			client, clientErr := tls.DialWithDialer(dialer, "tcp", listener.Addr().String(), config)
+			if clientErr == nil {
+				// TLS 1.3 中客户端证书在客户端握手完成后才被校验，读取一次以获得服务端的结果
+				client.SetDeadline(time.Now().Add(5 * time.Second))
+				_, clientErr = client.Read(make([]byte, 1))
+				if errors.Is(clientErr, io.EOF) {
+					clientErr = nil
+				}
+				client.Close()
+			}
+			if err := <-serverErr; (err == nil && clientErr == nil) != tt.ok {
+				t.Errorf("handshake client error %v, server error %v, want ok %v", clientErr, err, tt.ok)
+			}
+		})
+	}
+}
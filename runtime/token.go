@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	CredentialTLS   = "tls"
+	CredentialToken = "token"
+)
+
+// tokenCredentials 实现 credentials.PerRPCCredentials。
+// 令牌来自文件时，文件变化后会在下一次 RPC 重新读取，轮换令牌无需重启核心。
+// 长期打开的数据流只在建立时携带令牌，需要调用方借助 TokenFileVersion 发现轮换后重连。
+type tokenCredentials struct {
+	cc      CredentialConfig
+	secure  bool
+	lock    sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+func (tc *tokenCredentials) read() (string, error) {
+	switch {
+	case tc.cc.TokenFile != "":
+		tc.lock.Lock()
+		defer tc.lock.Unlock()
+		info, err := os.Stat(tc.cc.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("stat token file: %w", err)
+		}
+		if tc.token == "" || !info.ModTime().Equal(tc.modTime) || info.Size() != tc.size {
+			content, err := os.ReadFile(tc.cc.TokenFile)
+			if err != nil {
+				return "", fmt.Errorf("read token file: %w", err)
+			}
+			tc.token = strings.TrimSpace(string(content))
+			tc.modTime = info.ModTime()
+			tc.size = info.Size()
+		}
+		return tc.token, nil
+	case tc.cc.TokenEnv != "":
+		return os.Getenv(tc.cc.TokenEnv), nil
+	default:
+		return tc.cc.Token, nil
+	}
+}
+
+func (tc *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := tc.read()
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, fmt.Errorf("token credential is empty")
+	}
+
+	header := tc.cc.Header
+	if header == "" {
+		header = "authorization"
+	}
+	if tc.cc.Scheme != "" {
+		token = tc.cc.Scheme + " " + token
+	} else if header == "authorization" {
+		token = "Bearer " + token
+	}
+	return map[string]string{strings.ToLower(header): token}, nil
+}
+
+func (tc *tokenCredentials) RequireTransportSecurity() bool {
+	return tc.secure
+}
+
+func (cc CredentialConfig) PerRPCCredentials() credentials.PerRPCCredentials {
+	if cc.Type != CredentialToken {
+		return nil
+	}
+	return &tokenCredentials{
+		cc:     cc,
+		secure: cc.TLSEnabled(),
+	}
+}
+
+// TokenFileVersion 返回令牌文件的修改时间与大小，令牌不来自文件或文件无法读取时为空
+func (cc CredentialConfig) TokenFileVersion() string {
+	if cc.Type != CredentialToken || cc.TokenFile == "" {
+		return ""
+	}
+	info, err := os.Stat(cc.TokenFile)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+}
@@ -0,0 +1,99 @@
+package runtime
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MTMONITOR_TEST_TOKEN", "from-env")
+
+	tests := []struct {
+		name   string
+		cc     CredentialConfig
+		want   map[string]string
+		secure bool
+		err    bool
+	}{
+		{"inline bearer", CredentialConfig{Type: CredentialToken, Token: "t"}, map[string]string{"authorization": "Bearer t"}, false, false},
+		{"custom header", CredentialConfig{Type: CredentialToken, Token: "t", Header: "X-API-Key"}, map[string]string{"x-api-key": "t"}, false, false},
+		{"custom scheme", CredentialConfig{Type: CredentialToken, Token: "t", Scheme: "Token"}, map[string]string{"authorization": "Token t"}, false, false},
+		{"environment", CredentialConfig{Type: CredentialToken, TokenEnv: "MTMONITOR_TEST_TOKEN"}, map[string]string{"authorization": "Bearer from-env"}, false, false},
+		{"file trimmed", CredentialConfig{Type: CredentialToken, TokenFile: tokenFile}, map[string]string{"authorization": "Bearer from-file"}, false, false},
+		{"with tls", CredentialConfig{Type: CredentialToken, Token: "t", TLS: true}, map[string]string{"authorization": "Bearer t"}, true, false},
+		{"empty token", CredentialConfig{Type: CredentialToken, TokenEnv: "MTMONITOR_TEST_UNSET"}, nil, false, true},
+		{"missing file", CredentialConfig{Type: CredentialToken, TokenFile: tokenFile + ".missing"}, nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := tt.cc.PerRPCCredentials()
+			if creds == nil {
+				t.Fatal("PerRPCCredentials() = nil for a token credential")
+			}
+			if got := creds.RequireTransportSecurity(); got != tt.secure {
+				t.Errorf("RequireTransportSecurity() = %v, want %v", got, tt.secure)
+			}
+			md, err := creds.GetRequestMetadata(context.Background())
+			if (err != nil) != tt.err {
+				t.Fatalf("GetRequestMetadata() error = %v, want error %v", err, tt.err)
+			}
+			if !maps.Equal(md, tt.want) {
+				t.Errorf("GetRequestMetadata() = %v, want %v", md, tt.want)
+			}
+		})
+	}
+}
+
+// 令牌文件更新后下一次 RPC 使用新的令牌
+func TestTokenFileRotation(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds := CredentialConfig{Type: CredentialToken, TokenFile: tokenFile}.PerRPCCredentials()
+	for _, token := range []string{"old", "rotated"} {
+		if err := os.WriteFile(tokenFile, []byte(token), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		md, err := creds.GetRequestMetadata(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := md["authorization"]; got != "Bearer "+token {
+			t.Errorf("authorization = %q, want %q", got, "Bearer "+token)
+		}
+	}
+}
+
+func TestDialOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		cc   CredentialConfig
+		opts int
+	}{
+		{"plaintext", CredentialConfig{}, 1},
+		{"tls", CredentialConfig{TLS: true}, 1},
+		{"token", CredentialConfig{Type: CredentialToken, Token: "t"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cc.Type != CredentialToken && tt.cc.PerRPCCredentials() != nil {
+				t.Error("PerRPCCredentials() not nil without a token")
+			}
+			opts, err := tt.cc.DialOptions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(opts) != tt.opts {
+				t.Errorf("%d dial options, want %d", len(opts), tt.opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package runtime
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlTableHeader 解析 [a.b."c"] 或 [[a.b]] 形式的表头，返回各级键名，不是表头时 ok 为 false
+func tomlTableHeader(line string) ([]string, bool) {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "[") {
+		return nil, false
+	}
+	s = s[1:]
+	closing := "]"
+	if strings.HasPrefix(s, "[") {
+		s, closing = s[1:], "]]"
+	}
+
+	var parts []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		switch {
+		case strings.HasPrefix(s, `"`):
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, false
+			}
+			part, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil, false
+			}
+			parts, s = append(parts, part), s[end+1:]
+		case strings.HasPrefix(s, "'"):
+			end := strings.IndexByte(s[1:], '\'')
+			if end == -1 {
+				return nil, false
+			}
+			parts, s = append(parts, s[1:end+1]), s[end+2:]
+		default:
+			end := strings.IndexFunc(s, func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+			})
+			if end <= 0 {
+				return nil, false
+			}
+			parts, s = append(parts, s[:end]), s[end:]
+		}
+
+		s = strings.TrimLeft(s, " \t")
+		if strings.HasPrefix(s, ".") {
+			s = s[1:]
+			continue
+		}
+		if !strings.HasPrefix(s, closing) {
+			return nil, false
+		}
+		rest := strings.TrimSpace(s[len(closing):])
+		return parts, rest == "" || strings.HasPrefix(rest, "#")
+	}
+}
+
+// multilineState 返回读完该行后仍未结束的多行字符串分隔符，open 为行首时的状态
+func multilineState(line, open string) string {
+	for {
+		if open != "" {
+			i := strings.Index(line, open)
+			if i == -1 {
+				return open
+			}
+			line, open = line[i+3:], ""
+			continue
+		}
+		basic, literal := strings.Index(line, `"""`), strings.Index(line, `'''`)
+		switch {
+		case basic == -1 && literal == -1:
+			return ""
+		case literal == -1 || (basic != -1 && basic < literal):
+			line, open = line[basic+3:], `"""`
+		default:
+			line, open = line[literal+3:], `'''`
+		}
+	}
+}
+
+func isCommentOrBlank(line string) bool {
+	s := strings.TrimSpace(line)
+	return s == "" || strings.HasPrefix(s, "#")
+}
+
+// spliceCoreTable 将 [cores.<name>] 及其子表替换为 block，block 为空时删除。
+// 表之后紧邻下一个表头的注释属于下一个表，予以保留；删除时一并删除紧贴表头之上的注释。
+func spliceCoreTable(content, name, block string) string {
+	lines := strings.SplitAfter(content, "\n")
+	var out, skipped []string
+	insertAt := -1
+	skipping := false
+	open := ""
+
+	endBlock := func() {
+		keep := len(skipped)
+		for keep > 0 && isCommentOrBlank(skipped[keep-1]) {
+			keep--
+		}
+		out = append(out, skipped[keep:]...)
+		skipped = nil
+	}
+
+	for _, line := range lines {
+		if open == "" {
+			if parts, ok := tomlTableHeader(line); ok {
+				inCore := len(parts) >= 2 && parts[0] == "cores" && parts[1] == name
+				if skipping && !inCore {
+					endBlock()
+				}
+				if inCore && !skipping && insertAt == -1 {
+					if block == "" {
+						for len(out) > 0 && strings.HasPrefix(strings.TrimSpace(out[len(out)-1]), "#") {
+							out = out[:len(out)-1]
+						}
+					}
+					insertAt = len(out)
+				}
+				skipping = inCore
+			}
+		}
+		open = multilineState(line, open)
+		if skipping {
+			skipped = append(skipped, line)
+		} else {
+			out = append(out, line)
+		}
+	}
+	if skipping {
+		endBlock()
+	}
+
+	if block != "" {
+		if insertAt == -1 {
+			if n := len(out); n > 0 && !strings.HasSuffix(out[n-1], "\n") {
+				out[n-1] += "\n"
+			}
+			if len(out) > 0 {
+				out = append(out, "\n")
+			}
+			insertAt = len(out)
+		}
+		out = append(out[:insertAt], append([]string{block}, out[insertAt:]...)...)
+	}
+	return strings.Join(out, "")
+}
+
+func encodeCoreBlock(name string, table map[string]any) (string, error) {
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	if err := encoder.Encode(map[string]any{"cores": map[string]any{name: table}}); err != nil {
+		return "", err
+	}
+	block := strings.TrimPrefix(buf.String(), "[cores]\n")
+	if !strings.HasSuffix(block, "\n") {
+		block += "\n"
+	}
+	return block, nil
+}
+
+func withoutEmptyCores(raw map[string]any) map[string]any {
+	if cores, ok := raw["cores"].(map[string]any); ok && len(cores) == 0 {
+		delete(raw, "cores")
+	}
+	return raw
+}
+
+// editCoreTable 只改写配置文件中 [cores.<name>] 及其子表，table 为 nil 时删除该核心，
+// 其余内容（包括注释与键的顺序）保持不变。核心以内联表等无法单独定位的形式定义时，
+// 整体重新编码配置，此时注释会丢失。
+func editCoreTable(content []byte, name string, table map[string]any) ([]byte, error) {
+	expected := make(map[string]any)
+	if _, err := toml.Decode(string(content), &expected); err != nil {
+		return nil, err
+	}
+	cores, _ := expected["cores"].(map[string]any)
+	if cores == nil {
+		cores = make(map[string]any)
+		expected["cores"] = cores
+	}
+
+	block := ""
+	if table == nil {
+		delete(cores, name)
+	} else {
+		cores[name] = table
+		var err error
+		if block, err = encodeCoreBlock(name, table); err != nil {
+			return nil, err
+		}
+	}
+
+	edited := spliceCoreTable(string(content), name, block)
+	got := make(map[string]any)
+	if _, err := toml.Decode(edited, &got); err == nil && reflect.DeepEqual(withoutEmptyCores(got), withoutEmptyCores(expected)) {
+		return []byte(edited), nil
+	}
+	return encodeTOML(expected)
+}
+
+func pruneTable(table map[string]any) map[string]any {
+	for key, value := range table {
+		switch v := value.(type) {
+		case map[string]any:
+			if len(pruneTable(v)) == 0 {
+				delete(table, key)
+			}
+		case []map[string]any:
+			if len(v) == 0 {
+				delete(table, key)
+			}
+		case []any:
+			if len(v) == 0 {
+				delete(table, key)
+			}
+		default:
+			if reflect.ValueOf(value).IsZero() {
+				delete(table, key)
+			}
+		}
+	}
+	return table
+}
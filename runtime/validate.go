@@ -0,0 +1,160 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValidationError 单个配置项的校验错误，Key 为配置项路径
+type ValidationError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ve.Key, ve.Message)
+}
+
+type ValidationErrors []ValidationError
+
+func (ves ValidationErrors) Error() string {
+	lines := make([]string, 0, len(ves))
+	for _, ve := range ves {
+		lines = append(lines, ve.Error())
+	}
+	return fmt.Sprintf("配置校验失败:\n%s", strings.Join(lines, "\n"))
+}
+
+func (ves *ValidationErrors) add(key string, format string, a ...any) {
+	*ves = append(*ves, ValidationError{
+		Key:     key,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// sortedKeys 按名称排序，保证校验结果顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (c *Config) Validate() ValidationErrors {
+	var errs ValidationErrors
+
+	switch c.Persistence.Mode {
+	case "", PersistModeState, PersistModeConfig:
+	default:
+		errs.add("persistence.mode", "unknown mode %q, expected %q or %q", c.Persistence.Mode, PersistModeState, PersistModeConfig)
+	}
+	errs = append(errs, c.Storage.validate("storage")...)
+	errs = append(errs, c.Exporters.OTLP.validate("exporters.otlp")...)
+
+	for _, name := range sortedKeys(c.Credentials) {
+		cc := c.Credentials[name]
+		key := fmt.Sprintf("credentials.%s", name)
+		for _, file := range [][2]string{{"path", cc.Path}, {"ca", cc.CA}, {"cert", cc.Cert}, {"key", cc.Key}} {
+			if file[1] == "" {
+				continue
+			}
+			if f, err := os.Open(file[1]); err != nil {
+				errs.add(key+"."+file[0], "cannot read cert file: %s", err)
+			} else {
+				f.Close()
+			}
+		}
+		if (cc.Cert == "") != (cc.Key == "") {
+			errs.add(key, "cert and key must be set together for client certificates")
+		}
+		if _, ok := tlsVersions[cc.MinVersion]; cc.MinVersion != "" && !ok {
+			errs.add(key+".min_version", "unsupported TLS version %q, expected 1.0-1.3", cc.MinVersion)
+		}
+
+		switch cc.Type {
+		case "", CredentialTLS:
+		case CredentialToken:
+			sources := 0
+			for _, source := range []string{cc.Token, cc.TokenEnv, cc.TokenFile} {
+				if source != "" {
+					sources++
+				}
+			}
+			if sources != 1 {
+				errs.add(key, "token credential needs exactly one of token, token_env or token_file")
+			}
+			if !cc.TLSEnabled() && !cc.Insecure {
+				errs.add(key+".tls", "token would be sent in plaintext, enable TLS or set insecure = true")
+			}
+			if _, ok := os.LookupEnv(cc.TokenEnv); cc.TokenEnv != "" && !ok {
+				errs.add(key+".token_env", "environment variable %s is not set", cc.TokenEnv)
+			}
+			if cc.TokenFile != "" {
+				if f, err := os.Open(cc.TokenFile); err != nil {
+					errs.add(key+".token_file", "cannot read token file: %s", err)
+				} else {
+					f.Close()
+				}
+			}
+		default:
+			errs.add(key+".type", "unknown credential type %q, expected %q or %q", cc.Type, CredentialTLS, CredentialToken)
+		}
+	}
+
+	addresses := make(map[string]string)
+	for _, name := range sortedKeys(c.Cores) {
+		cc := c.Cores[name]
+		errs = append(errs, c.ValidateCore(name, cc)...)
+		if address, err := cc.Address(); err == nil {
+			if other, ok := addresses[address]; ok {
+				errs.add(fmt.Sprintf("cores.%s", name), "duplicate address %s, already used by cores.%s", address, other)
+			} else {
+				addresses[address] = name
+			}
+		}
+	}
+
+	return errs
+}
+
+// ValidateCore 校验单个核心的生效配置，通过 API 添加的核心同样需要经过校验
+func (c *Config) ValidateCore(name string, cc CoreConfig) ValidationErrors {
+	var errs ValidationErrors
+	key := fmt.Sprintf("cores.%s", name)
+
+	if _, err := cc.Address(); err != nil {
+		field := ".target"
+		if cc.Target == "" {
+			field = ".host"
+			if cc.Host != "" {
+				field = ".port"
+			}
+		}
+		errs.add(key+field, "%s", err)
+	}
+
+	if interval, err := time.ParseDuration(cc.Interval); err != nil {
+		errs.add(key+".interval", "invalid duration %q", cc.Interval)
+	} else if interval <= 0 {
+		errs.add(key+".interval", "interval must be positive, got %s", cc.Interval)
+	}
+
+	if _, ok := c.Credentials[cc.Credential]; !ok {
+		errs.add(key+".credential", "unknown credential %q", cc.Credential)
+	}
+
+	errs = append(errs, cc.HealthCheck.validate(key+".health_check")...)
+	errs = append(errs, cc.Stats.validate(key+".stats")...)
+	errs = append(errs, cc.History.validate(key+".history")...)
+	errs = append(errs, validateTags(key+".tags", cc.Tags)...)
+
+	if _, err := cc.Reconnect.Policy(); err != nil {
+		errs.add(key+".reconnect", "%s", err)
+	}
+	return errs
+}
@@ -0,0 +1,162 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// validCore 通过校验的最小核心配置
+func validCore(host string, port int) CoreConfig {
+	return CoreConfig{
+		Host:       host,
+		Port:       port,
+		Interval:   "1s",
+		Credential: "c",
+	}
+}
+
+func validationKeys(errs ValidationErrors) []string {
+	var keys []string
+	for _, ve := range errs {
+		keys = append(keys, ve.Key)
+	}
+	return keys
+}
+
+func TestValidateHealthRuleNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		disabled []string
+		severity map[string]SeverityConfig
+		want     []string
+	}{
+		{"none", nil, nil, nil},
+		{"known", []string{"thread-blocking"}, map[string]SeverityConfig{"low-thread-usage": {Severity: "critical"}, "stream-stale": {}}, nil},
+		{"unknown disabled", []string{"low-thread-usage", "nope"}, nil, []string{"cores.%s.health_check.disabled_rules[1]"}},
+		{"issue is not a rule", []string{"stream-stale"}, nil, []string{"cores.%s.health_check.disabled_rules[0]"}},
+		{"unknown severity sorted", nil, map[string]SeverityConfig{"zz": {}, "aa": {}, "low-thread-usage": {}}, []string{
+			"cores.%s.health_check.severity.aa", "cores.%s.health_check.severity.zz",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Credentials: map[string]CredentialConfig{"c": {}}, Cores: map[string]CoreConfig{}}
+			// 多个核心时按核心名排序输出
+			var want []string
+			for _, name := range []string{"b", "a"} {
+				cc := validCore(name+".local", 9000)
+				cc.HealthCheck.DisabledRules = tt.disabled
+				cc.HealthCheck.Severity = tt.severity
+				cfg.Cores[name] = cc
+			}
+			for _, name := range []string{"a", "b"} {
+				for _, key := range tt.want {
+					want = append(want, fmt.Sprintf(key, name))
+				}
+			}
+			if got := validationKeys(cfg.Validate()); !slices.Equal(got, want) {
+				t.Errorf("Validate() keys = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRegisterHealthRule(t *testing.T) {
+	t.Cleanup(func() { delete(healthRules, "plugin-rule") })
+	if err := RegisterHealthRule("plugin-rule"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"thread-blocking", "plugin-rule", IssueStreamStale} {
+		if err := RegisterHealthRule(name); err == nil {
+			t.Errorf("RegisterHealthRule(%q) succeeded", name)
+		}
+	}
+
+	cc := validCore("a.local", 9000)
+	cc.HealthCheck.DisabledRules = []string{"plugin-rule"}
+	cfg := &Config{Credentials: map[string]CredentialConfig{"c": {}}, Cores: map[string]CoreConfig{"a": cc}}
+	if errs := cfg.Validate(); len(errs) > 0 {
+		t.Errorf("Validate() = %v", errs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{"valid", func(cfg *Config) {}, nil},
+		{"unknown persistence mode", func(cfg *Config) { cfg.Persistence.Mode = "db" }, []string{"persistence.mode"}},
+		{"invalid tag names", func(cfg *Config) {
+			cc := cfg.Cores["a"]
+			cc.Tags = map[string]string{"env": "prod", "core": "x", "bad-name": "y", "__meta": "z"}
+			cfg.Cores["a"] = cc
+		}, []string{"cores.a.tags.__meta", "cores.a.tags.bad-name", "cores.a.tags.core"}},
+		{"bad storage durations", func(cfg *Config) {
+			cfg.Storage = StorageConfig{Dir: "data", CompactInterval: "0s", Retention: StorageRetention{Minute: "7d"}}
+		}, []string{"storage.compact_interval", "storage.retention.1m"}},
+		{"bad otlp exporter", func(cfg *Config) {
+			cfg.Exporters.OTLP = OTLPConfig{Endpoint: "collector:4318", Protocol: OTLPProtocolHTTP, Insecure: true, FlushInterval: "later", QueueSize: -1}
+		}, []string{"exporters.otlp.endpoint", "exporters.otlp.insecure", "exporters.otlp.flush_interval", "exporters.otlp.queue_size"}},
+		{"unknown otlp protocol", func(cfg *Config) {
+			cfg.Exporters.OTLP = OTLPConfig{Endpoint: "collector:4317", Protocol: "http/json"}
+		}, []string{"exporters.otlp.protocol"}},
+		{"missing host", func(cfg *Config) {
+			cc := cfg.Cores["a"]
+			cc.Host = ""
+			cfg.Cores["a"] = cc
+		}, []string{"cores.a.host"}},
+		{"port out of range", func(cfg *Config) {
+			cc := cfg.Cores["a"]
+			cc.Port = 70000
+			cfg.Cores["a"] = cc
+		}, []string{"cores.a.port"}},
+		{"duplicate address", func(cfg *Config) { cfg.Cores["b"] = validCore("127.0.0.1", 9000) }, []string{"cores.b"}},
+		{"bad interval", func(cfg *Config) {
+			cc := cfg.Cores["a"]
+			cc.Interval = "soon"
+			cfg.Cores["a"] = cc
+		}, []string{"cores.a.interval"}},
+		{"non-positive interval", func(cfg *Config) {
+			cc := cfg.Cores["a"]
+			cc.Interval = "0s"
+			cfg.Cores["a"] = cc
+		}, []string{"cores.a.interval"}},
+		{"unknown credential", func(cfg *Config) {
+			cc := cfg.Cores["a"]
+			cc.Credential = "nope"
+			cfg.Cores["a"] = cc
+		}, []string{"cores.a.credential"}},
+		{"health check ranges", func(cfg *Config) {
+			cc := cfg.Cores["a"]
+			cc.HealthCheck = HealthCheckConfig{MinUsageRate: 2}
+			cfg.Cores["a"] = cc
+		}, []string{"cores.a.health_check.min_usage_rate"}},
+		{"missing cert file", func(cfg *Config) { cfg.Credentials["c"] = CredentialConfig{Path: "testdata/missing.pem"} }, []string{"credentials.c.path"}},
+		{"cert without key", func(cfg *Config) { cfg.Credentials["c"] = CredentialConfig{Cert: "testdata/missing.pem"} }, []string{"credentials.c.cert", "credentials.c"}},
+		{"unsupported TLS version", func(cfg *Config) { cfg.Credentials["c"] = CredentialConfig{MinVersion: "0.9"} }, []string{"credentials.c.min_version"}},
+		{"unknown credential type", func(cfg *Config) { cfg.Credentials["c"] = CredentialConfig{Type: "basic"} }, []string{"credentials.c.type"}},
+		{"token without source", func(cfg *Config) { cfg.Credentials["c"] = CredentialConfig{Type: CredentialToken, TLS: true} }, []string{"credentials.c"}},
+		{"token with two sources", func(cfg *Config) {
+			cfg.Credentials["c"] = CredentialConfig{Type: CredentialToken, Token: "t", TokenEnv: "MTMONITOR_TEST_UNSET", TLS: true}
+		}, []string{"credentials.c", "credentials.c.token_env"}},
+		{"token without tls", func(cfg *Config) { cfg.Credentials["c"] = CredentialConfig{Type: CredentialToken, Token: "t"} }, []string{"credentials.c.tls"}},
+		{"token in plaintext opted in", func(cfg *Config) {
+			cfg.Credentials["c"] = CredentialConfig{Type: CredentialToken, Token: "t", Insecure: true}
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Credentials: map[string]CredentialConfig{"c": {}},
+				Cores:       map[string]CoreConfig{"a": validCore("127.0.0.1", 9000)},
+			}
+			tt.modify(cfg)
+			if got := validationKeys(cfg.Validate()); !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+type ConfigWatcher struct {
+	path     string
+	interval time.Duration
+	digest   []byte
+}
+
+// Watch 阻塞运行直到 ctx 结束，每次配置变化（或收到 SIGHUP）时调用 onReload。
+// 解析失败时 cfg 为 nil，err 为具体错误，调用方应继续使用旧配置。
+func (cw *ConfigWatcher) Watch(ctx context.Context, onReload func(cfg *Config, err error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if cw.interval > 0 {
+		ticker := time.NewTicker(cw.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			cw.digest, _ = fileDigest(cw.path)
+			onReload(LoadConfig(cw.path))
+		case <-tick:
+			digest, err := fileDigest(cw.path)
+			if err != nil || bytes.Equal(digest, cw.digest) {
+				continue
+			}
+			cw.digest = digest
+			onReload(LoadConfig(cw.path))
+		}
+	}
+}
+
+func fileDigest(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	return sum[:], nil
+}
+
+// NewConfigWatcher 创建配置监听器，interval 为 0 时只响应 SIGHUP
+func NewConfigWatcher(path string, interval time.Duration) *ConfigWatcher {
+	if path == "" {
+		path = "config.toml"
+	}
+	digest, _ := fileDigest(path)
+	return &ConfigWatcher{
+		path:     path,
+		interval: interval,
+		digest:   digest,
+	}
+}
@@ -0,0 +1,59 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigWatcherReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("[cores.a]\nhost = \"a\"\nport = 1\n")
+
+	type reload struct {
+		cfg *Config
+		err error
+	}
+	reloads := make(chan reload, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewConfigWatcher(path, 5*time.Millisecond).Watch(ctx, func(cfg *Config, err error) {
+		reloads <- reload{cfg, err}
+	})
+
+	next := func() reload {
+		t.Helper()
+		select {
+		case r := <-reloads:
+			return r
+		case <-time.After(2 * time.Second):
+			t.Fatal("no reload")
+			return reload{}
+		}
+	}
+
+	// 内容未变化时不触发
+	select {
+	case r := <-reloads:
+		t.Fatalf("unexpected reload: %+v", r)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	write("[cores.a]\nhost = \"a\"\nport = 1\n[cores.b]\nhost = \"b\"\nport = 2\n")
+	if r := next(); r.err != nil || len(r.cfg.Cores) != 2 {
+		t.Fatalf("reload = %+v, want 2 cores", r)
+	}
+
+	write("[cores.a\n")
+	if r := next(); r.err == nil || r.cfg != nil {
+		t.Fatalf("reload of invalid file = %+v, want error", r)
+	}
+}
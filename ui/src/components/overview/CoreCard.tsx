@@ -37,7 +37,7 @@ const CoreCard: React.FC<CoreCardProps> = ({ core, metrics, onDelete }) => {
         
         {/* 核心连接信息 */}
         <div className="text-sm text-slate-300 mb-3 flex items-center justify-between">
-          <div className="font-mono">{core.host}:{core.port}</div>
+          <div className="font-mono truncate" title={core.target}>{core.target}</div>
           <div className="text-xs text-slate-400">{core.interval}</div>
         </div>
         
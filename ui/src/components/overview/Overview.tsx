@@ -2,7 +2,7 @@ import React, { useState, useEffect } from 'react';
 import { toast } from 'react-hot-toast';
 import CoreCard from './CoreCard';
 import { useWebSocketContext } from '../../contexts/WebSocketContext';
-import { useAppData, Core } from '../../contexts/AppDataContext';
+import { useAppData, NewCore } from '../../contexts/AppDataContext';
 
 // 更新凭证类型定义，现在只有名称
 type Credential = string;
@@ -57,8 +57,9 @@ const Overview: React.FC = () => {
   const [coreToDelete, setCoreToDelete] = useState<string | null>(null);
   
   // 用于添加Core的表单状态
-  const [newCore, setNewCore] = useState<Omit<Core, 'name'> & { name: string, credential_name: string }>({
+  const [newCore, setNewCore] = useState<NewCore>({
     name: '',
+    target: '',
     host: 'localhost',
     port: 50051,
     interval: '1s',
@@ -101,6 +102,7 @@ const Overview: React.FC = () => {
       // 重置表单
       setNewCore({
         name: '',
+        target: '',
         host: 'localhost',
         port: 50051,
         interval: '1s',
@@ -202,6 +204,18 @@ const Overview: React.FC = () => {
                 />
               </div>
               
+              <div>
+                <label className="block text-sm font-medium text-slate-300 mb-1">目标地址</label>
+                <input
+                  type="text"
+                  name="target"
+                  value={newCore.target}
+                  onChange={handleInputChange}
+                  className="w-full px-3 py-2 bg-slate-700 border border-slate-600 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                  placeholder="如: unix:///run/x.sock、[::1]:9000，留空时使用主机与端口"
+                />
+              </div>
+              
               <div>
                 <label className="block text-sm font-medium text-slate-300 mb-1">主机</label>
                 <input
@@ -209,6 +223,7 @@ const Overview: React.FC = () => {
                   name="host"
                   value={newCore.host}
                   onChange={handleInputChange}
+                  disabled={!!newCore.target}
                   className="w-full px-3 py-2 bg-slate-700 border border-slate-600 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
                   placeholder="主机名或IP"
                 />
@@ -221,6 +236,7 @@ const Overview: React.FC = () => {
                   name="port"
                   value={newCore.port}
                   onChange={handleInputChange}
+                  disabled={!!newCore.target}
                   className="w-full px-3 py-2 bg-slate-700 border border-slate-600 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
                   placeholder="端口号"
                 />
@@ -268,7 +284,7 @@ const Overview: React.FC = () => {
               </button>
               <button
                 onClick={handleAddCore}
-                disabled={!newCore.name || !newCore.host || !newCore.port || !newCore.interval || !newCore.credential_name}
+                disabled={!newCore.name || (!newCore.target && (!newCore.host || !newCore.port)) || !newCore.interval || !newCore.credential_name}
                 className="px-4 py-2 bg-blue-600 hover:bg-blue-700 rounded-md disabled:opacity-50 disabled:cursor-not-allowed"
               >
                 添加
@@ -1,15 +1,20 @@
 import React, { createContext, useContext, useEffect, useState, useRef } from 'react';
 import { useWebSocketContext } from './WebSocketContext';
-import { Metrics, LogEntry, LogLevel, WebSocketMessage } from '../types';
+import { Metrics, LogEntry, LogLevel, WebSocketMessage, CoreSummary } from '../types';
 
 
-// 核心类型定义
-export interface Core {
+// 核心类型定义，target 为后端规范化后的连接地址
+export type Core = CoreSummary;
+
+// 添加核心时提交的数据，target 与 host/port 二选一
+export type NewCore = {
   name: string;
+  target: string;
   host: string;
   port: number;
   interval: string;
-}
+  credential_name: string;
+};
 
 
 
@@ -46,7 +51,7 @@ interface AppDataContextType {
   deleteCore: (name: string) => Promise<boolean>;
   
   // 添加核心
-  addCore: (core: Omit<Core, 'name'> & { name: string, credential_name: string }) => Promise<boolean>;
+  addCore: (core: NewCore) => Promise<boolean>;
 
   // 获取特定核心的间隔
   getCoreInterval: (name: string) => string;
@@ -149,14 +154,15 @@ export const AppDataProvider: React.FC<{children: React.ReactNode}> = ({ childre
   };
   
   // 添加核心
-  const addCore = async (coreData: Omit<Core, 'name'> & { name: string, credential_name: string }): Promise<boolean> => {
+  const addCore = async (coreData: NewCore): Promise<boolean> => {
     try {
       const response = await fetch('/api/cores', {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json'
         },
-        body: JSON.stringify(coreData)
+        // 填写了目标地址时不再提交主机与端口
+        body: JSON.stringify(coreData.target ? { ...coreData, host: '', port: 0 } : coreData)
       });
       
       if (!response.ok) throw new Error('Failed to add core');
@@ -178,7 +184,18 @@ export const AppDataProvider: React.FC<{children: React.ReactNode}> = ({ childre
       const wsMessage = message as WebSocketMessage;
       const coreName = wsMessage.name;
       
-      if (wsMessage.type === 'metrics') {
+      if (wsMessage.type === 'cores') {
+        // 核心增删后服务端推送完整列表
+        setCores(wsMessage.data);
+      }
+      else if (wsMessage.type === 'core_status') {
+        // 更新对应核心的连接状态与地址
+        const status = wsMessage.data;
+        setCores(prev => prev.map(core =>
+          core.name === coreName ? { ...core, target: status.target, status } : core
+        ));
+      }
+      else if (wsMessage.type === 'metrics') {
         // 更新metrics和连接状态
         const metricsData = wsMessage.data;
         
@@ -74,7 +74,43 @@ interface WebSocketEventsMessage {
   type: 'events';
 }
 
-export type WebSocketMessage = WebSocketMetricsMessage | WebSocketEventsMessage;
+// 核心连接状态，与后端 CoreStatus 对应
+export interface CoreStatus {
+  state: 'idle' | 'connecting' | 'streaming' | 'backoff' | 'failed' | 'stopped';
+  target: string;          // 规范化后的连接地址，如 127.0.0.1:9000、[::1]:9000、unix:///run/x.sock
+  last_error?: string;
+  error_kind?: string;
+  attempts: number;
+  connected_since?: string;
+  last_message_at?: string;
+  next_retry_at?: string;
+  changed_at: string;
+}
+
+// 核心列表中的一项，与 /api/cores 返回的结构一致
+export interface CoreSummary {
+  name: string;
+  target: string;
+  host: string;
+  port: number;
+  interval: string;
+  status?: CoreStatus;
+}
+
+interface WebSocketCoreStatusMessage {
+  data: CoreStatus;
+  name: string;
+  type: 'core_status';
+}
+
+// 核心增删时推送的完整列表，连接建立时也会推送一次
+interface WebSocketCoresMessage {
+  data: CoreSummary[];
+  name: string;
+  type: 'cores';
+}
+
+export type WebSocketMessage = WebSocketMetricsMessage | WebSocketEventsMessage | WebSocketCoreStatusMessage | WebSocketCoresMessage;
 
 // 添加到现有类型定义中
 
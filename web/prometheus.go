@@ -0,0 +1,172 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/B9O2/mtmonitor/core"
+	"github.com/gin-gonic/gin"
+)
+
+// promLabel 指标标签，按添加顺序输出
+type promLabel struct {
+	name  string
+	value string
+}
+
+type promFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []string
+}
+
+// promRegistry 按 Prometheus 文本格式收集一次抓取的指标，同名指标的样本归入同一组
+type promRegistry struct {
+	families []*promFamily
+	index    map[string]*promFamily
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (r *promRegistry) add(name, kind, help string, labels []promLabel, value float64) {
+	family, ok := r.index[name]
+	if !ok {
+		family = &promFamily{name: name, kind: kind, help: help}
+		r.families = append(r.families, family)
+		r.index[name] = family
+	}
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(labels) > 0 {
+		sb.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, `%s="%s"`, l.name, promEscaper.Replace(l.value))
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	family.samples = append(family.samples, sb.String())
+}
+
+func (r *promRegistry) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	for _, family := range r.families {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+		for _, sample := range family.samples {
+			sb.WriteString(sample)
+			sb.WriteByte('\n')
+		}
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func newPromRegistry() *promRegistry {
+	return &promRegistry{index: make(map[string]*promFamily)}
+}
+
+// withLabel 返回追加了一个标签的新切片，不修改 labels
+func withLabel(labels []promLabel, name, value string) []promLabel {
+	return append(slices.Clip(labels), promLabel{name, value})
+}
+
+// coreLabels 核心名及配置中的标签，标签按名称排序
+func coreLabels(name string, mtCore *MTCore) []promLabel {
+	labels := []promLabel{{"core", name}}
+	tags := make([]string, 0, len(mtCore.Tags))
+	for tag := range mtCore.Tags {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	for _, tag := range tags {
+		labels = append(labels, promLabel{tag, mtCore.Tags[tag]})
+	}
+	return labels
+}
+
+func (mws *MonitorWebServer) collectCore(r *promRegistry, name string, mtCore *MTCore, perThread bool) {
+	labels := coreLabels(name, mtCore)
+
+	status := mtCore.Status()
+	for _, state := range ConnStates {
+		value := 0.0
+		if status.State == state {
+			value = 1
+		}
+		r.add("mtmonitor_core_connection_state", "gauge", "Connection state of the core, 1 for the current state.",
+			withLabel(labels, "state", string(state)), value)
+	}
+
+	open := make(map[core.Severity]int)
+	for _, issue := range mtCore.collector.Issues(core.IssueOpen, "") {
+		open[issue.Severity]++
+	}
+	for _, severity := range []core.Severity{core.SeverityInfo, core.SeverityWarning, core.SeverityCritical} {
+		r.add("mtmonitor_core_health_issues", "gauge", "Open health issues by severity.",
+			withLabel(labels, "severity", string(severity)), float64(open[severity]))
+	}
+
+	m := mtCore.collector.Latest()
+	if m == nil {
+		return
+	}
+	r.add("mtmonitor_core_tasks_total", "counter", "Tasks submitted to the core.", labels, float64(m.TotalTask))
+	r.add("mtmonitor_core_results_total", "counter", "Results produced by the core.", labels, float64(m.TotalResult))
+	r.add("mtmonitor_core_retries_total", "counter", "Tasks retried by the core.", labels, float64(m.TotalRetry))
+	r.add("mtmonitor_core_retry_queue_size", "gauge", "Tasks waiting in the retry queue.", labels, float64(m.RetrySize))
+	r.add("mtmonitor_core_speed", "gauge", "Results per second over the last interval.", labels, m.Speed)
+	r.add("mtmonitor_core_threads", "gauge", "Threads in the pool by state.", withLabel(labels, "state", "working"), float64(m.Working))
+	r.add("mtmonitor_core_threads", "gauge", "Threads in the pool by state.", withLabel(labels, "state", "idle"), float64(m.Idle))
+	r.add("mtmonitor_core_usage_ratio", "gauge", "Share of threads that are working.", labels, m.UsageRate())
+
+	if !perThread {
+		return
+	}
+	for tid, ts := range m.ThreadsDetail.ThreadsStatus {
+		threadLabels := withLabel(labels, "thread", strconv.Itoa(tid))
+		working := 0.0
+		if ts == 1 {
+			working = 1
+		}
+		r.add("mtmonitor_thread_working", "gauge", "Whether the thread is working on a task.", threadLabels, working)
+		if tid < len(m.ThreadsDetail.ThreadsCount) {
+			r.add("mtmonitor_thread_tasks_total", "counter", "Tasks completed by the thread.", threadLabels, float64(m.ThreadsDetail.ThreadsCount[tid]))
+		}
+		if tid < len(m.ThreadsRate) {
+			r.add("mtmonitor_thread_rate", "gauge", "Tasks per second completed by the thread over the last interval.", threadLabels, m.ThreadsRate[tid])
+		}
+	}
+}
+
+// handleMetrics Prometheus 抓取端点，尚未收到数据的核心只导出连接状态与健康问题
+func (mws *MonitorWebServer) handleMetrics(c *gin.Context) {
+	mws.reloadLock.Lock()
+	perThread := mws.config != nil && mws.config.Prometheus.PerThread
+	mws.reloadLock.Unlock()
+
+	var names []string
+	cores := make(map[string]*MTCore)
+	mws.cores.Range(func(key, value any) bool {
+		names = append(names, key.(string))
+		cores[key.(string)] = value.(*MTCore)
+		return true
+	})
+	slices.Sort(names)
+
+	r := newPromRegistry()
+	for _, name := range names {
+		mws.collectCore(r, name, cores[name], perThread)
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(c.Writer)
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/B9O2/monitors/monitor"
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		perThread bool
+		want      []string
+		absent    []string
+	}{
+		{"core series", false, []string{
+			`# TYPE mtmonitor_core_tasks_total counter`,
+			`mtmonitor_core_tasks_total{core="a",env="prod",team="x\"y"} 100`,
+			`mtmonitor_core_results_total{core="a",env="prod",team="x\"y"} 40`,
+			`mtmonitor_core_retries_total{core="a",env="prod",team="x\"y"} 3`,
+			`mtmonitor_core_retry_queue_size{core="a",env="prod",team="x\"y"} 2`,
+			`mtmonitor_core_threads{core="a",env="prod",team="x\"y",state="working"} 1`,
+			`mtmonitor_core_threads{core="a",env="prod",team="x\"y",state="idle"} 1`,
+			`mtmonitor_core_usage_ratio{core="a",env="prod",team="x\"y"} 0.5`,
+			`mtmonitor_core_connection_state{core="a",env="prod",team="x\"y",state="streaming"} 1`,
+			`mtmonitor_core_connection_state{core="a",env="prod",team="x\"y",state="failed"} 0`,
+			`mtmonitor_core_health_issues{core="a",env="prod",team="x\"y",severity="critical"} 0`,
+			`mtmonitor_core_connection_state{core="b",state="streaming"} 1`,
+		}, []string{"mtmonitor_thread_", `mtmonitor_core_tasks_total{core="b"`}},
+		{"per thread", true, []string{
+			`mtmonitor_thread_tasks_total{core="a",env="prod",team="x\"y",thread="0"} 30`,
+			`mtmonitor_thread_working{core="a",env="prod",team="x\"y",thread="1"} 0`,
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mws, dialer := newTestServer(t)
+			mws.config = &runtime.Config{Prometheus: runtime.PrometheusConfig{PerThread: tt.perThread}}
+			cc := testCore("127.0.0.1:9001")
+			cc.Tags = map[string]string{"team": `x"y`, "env": "prod"}
+			if err := mws.AddCore("a", cc); err != nil {
+				t.Fatal(err)
+			}
+			dialer.next(t)
+			if err := mws.AddCore("b", testCore("127.0.0.1:9002")); err != nil {
+				t.Fatal(err)
+			}
+			dialer.next(t)
+			a := loadCore(t, mws, "a")
+			waitState(t, a, StateStreaming)
+			waitState(t, loadCore(t, mws, "b"), StateStreaming)
+			a.collector.Update(&monitor.Status{
+				TotalTask: 100, TotalResult: 40, TotalRetry: 3, RetrySize: 2,
+				ThreadsDetail: &monitor.ThreadsDetail{ThreadsStatus: []int32{1, 0}, ThreadsCount: []uint64{30, 10}},
+			})
+
+			w := doJSON(t, mws, http.MethodGet, "/metrics", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			body := w.Body.String()
+			for _, line := range tt.want {
+				if !strings.Contains(body, line+"\n") {
+					t.Errorf("missing %s in:\n%s", line, body)
+				}
+			}
+			for _, fragment := range tt.absent {
+				if strings.Contains(body, fragment) {
+					t.Errorf("unexpected %s in:\n%s", fragment, body)
+				}
+			}
+			if n := strings.Count(body, "# TYPE mtmonitor_core_connection_state "); n != 1 {
+				t.Errorf("connection_state family declared %d times", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+// ConfigReload 一次配置重载的结果，通过 WebSocket 以 config_reload 消息推送
+type ConfigReload struct {
+	Time      time.Time         `json:"time"`
+	Success   bool              `json:"success"`
+	Error     string            `json:"error,omitempty"`
+	Added     []string          `json:"added"`
+	Removed   []string          `json:"removed"`
+	Restarted []string          `json:"restarted"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+func (mws *MonitorWebServer) runningCores() map[string]runtime.CoreConfig {
+	running := make(map[string]runtime.CoreConfig)
+	mws.cores.Range(func(key, value any) bool {
+		running[key.(string)] = *value.(*MTCore).CoreConfig
+		return true
+	})
+	return running
+}
+
+// resolveCore 按当前配置的 defaults 与 groups 计算核心的生效配置，调用方需持有 reloadLock
+func (mws *MonitorWebServer) resolveCore(name string, cc runtime.CoreConfig) (runtime.CoreConfig, error) {
+	if mws.config == nil {
+		return cc, nil
+	}
+	return mws.config.ResolveCore(name, cc)
+}
+
+// validateCore 按加载配置文件时的规则校验核心的生效配置，调用方需持有 reloadLock
+func (mws *MonitorWebServer) validateCore(name string, cc runtime.CoreConfig) runtime.ValidationErrors {
+	cfg := mws.config
+	if cfg == nil {
+		// 未加载配置文件时只有创建服务时传入的凭证
+		cfg = &runtime.Config{Credentials: make(map[string]runtime.CredentialConfig)}
+		mws.credLock.RLock()
+		for _, cred := range mws.credentials {
+			cfg.Credentials[cred.Name] = cred.CredentialConfig
+		}
+		mws.credLock.RUnlock()
+	}
+	return cfg.ValidateCore(name, cc)
+}
+
+func (mws *MonitorWebServer) redactedCore(name string, cc runtime.CoreConfig) runtime.CoreConfig {
+	mws.reloadLock.Lock()
+	defer mws.reloadLock.Unlock()
+	if mws.config == nil {
+		return cc
+	}
+	return mws.config.RedactedCore(name, cc)
+}
+
+// rememberAPICore 记录未持久化时通过 API 添加的核心，重载配置时保留，调用方需持有 reloadLock
+func (mws *MonitorWebServer) rememberAPICore(name string, cc runtime.CoreConfig) {
+	if mws.apiCores == nil {
+		mws.apiCores = make(map[string]runtime.CoreConfig)
+	}
+	mws.apiCores[name] = cc
+}
+
+// forgetAPICore 通过 API 删除核心时调用，调用方需持有 reloadLock
+func (mws *MonitorWebServer) forgetAPICore(name string) {
+	delete(mws.apiCores, name)
+}
+
+// desiredCores 新配置中的核心加上未持久化的 API 核心。
+// 配置文件中定义了同名核心时以配置文件为准，该核心不再视为 API 核心。
+func (mws *MonitorWebServer) desiredCores(cfg *runtime.Config, running map[string]runtime.CoreConfig) map[string]runtime.CoreConfig {
+	if len(mws.apiCores) == 0 {
+		return cfg.Cores
+	}
+	desired := maps.Clone(cfg.Cores)
+	if desired == nil {
+		desired = make(map[string]runtime.CoreConfig)
+	}
+	for name, cc := range mws.apiCores {
+		if _, ok := desired[name]; ok {
+			delete(mws.apiCores, name)
+			continue
+		}
+		if effective, err := cfg.ResolveCore(name, cc); err == nil {
+			desired[name] = effective
+		} else if current, ok := running[name]; ok {
+			desired[name] = current
+		}
+	}
+	return desired
+}
+
+func (mws *MonitorWebServer) ApplyConfig(cfg *runtime.Config) *ConfigReload {
+	mws.reloadLock.Lock()
+	defer mws.reloadLock.Unlock()
+
+	credentials := NewCredentials(cfg.Credentials)
+	mws.credLock.Lock()
+	changedCredentials := diffCredentials(mws.credentials, credentials)
+	mws.credentials = credentials
+	mws.credLock.Unlock()
+
+	mws.config = cfg
+
+	running := mws.runningCores()
+	desired := mws.desiredCores(cfg, running)
+	diff := runtime.DiffCores(running, desired, changedCredentials)
+	report := &ConfigReload{
+		Time:      time.Now(),
+		Success:   true,
+		Added:     []string{},
+		Removed:   []string{},
+		Restarted: []string{},
+		Failed:    map[string]string{},
+	}
+
+	for _, name := range diff.Removed {
+		if err := mws.removeCore(name); err != nil {
+			report.Failed[name] = err.Error()
+			continue
+		}
+		report.Removed = append(report.Removed, name)
+	}
+	for _, name := range diff.Changed {
+		if err := mws.replaceCore(name, desired[name]); err != nil {
+			report.Failed[name] = err.Error()
+			continue
+		}
+		report.Restarted = append(report.Restarted, name)
+	}
+	for _, name := range diff.Added {
+		if err := mws.AddCore(name, desired[name]); err != nil {
+			report.Failed[name] = err.Error()
+			continue
+		}
+		report.Added = append(report.Added, name)
+	}
+
+	if len(report.Failed) > 0 {
+		report.Success = false
+		report.Error = fmt.Sprintf("%d core(s) failed to apply", len(report.Failed))
+	}
+	return report
+}
+
+// replaceCore 先按新配置构建核心，构建失败时旧核心继续运行；新核心未能启动时按旧配置重新添加
+func (mws *MonitorWebServer) replaceCore(name string, cfg runtime.CoreConfig) error {
+	mtCore, cred, err := mws.newCore(name, cfg)
+	if err != nil {
+		return err
+	}
+	previous, ok := mws.cores.Load(name)
+	if err := mws.removeCore(name); err != nil {
+		mtCore.Cancel()
+		return err
+	}
+	if err := mws.startCore(name, mtCore, cred); err != nil {
+		if ok {
+			err = errors.Join(err, mws.AddCore(name, *previous.(*MTCore).CoreConfig))
+		}
+		return err
+	}
+	return nil
+}
+
+// removeCore 移除核心，核心未能及时停止时只打印警告：它已不在核心列表中，可以按新配置重新添加
+func (mws *MonitorWebServer) removeCore(name string) error {
+	err := mws.RemoveCore(name)
+	if errors.Is(err, ErrStopTimeout) {
+		fmt.Printf("[!]%s\n", err)
+		return nil
+	}
+	return err
+}
+
+// Reload 处理配置监听器的回调：解析失败时保留旧配置，结果都会广播给客户端
+func (mws *MonitorWebServer) Reload(cfg *runtime.Config, err error) {
+	var report *ConfigReload
+	if err == nil {
+		if errs := cfg.Validate(); len(errs) > 0 {
+			err = errs
+		}
+	}
+	if err != nil {
+		report = &ConfigReload{
+			Time:      time.Now(),
+			Error:     err.Error(),
+			Added:     []string{},
+			Removed:   []string{},
+			Restarted: []string{},
+		}
+		fmt.Printf("[x]Config reload failed, keeping previous config: %s\n", err)
+	} else {
+		report = mws.ApplyConfig(cfg)
+		fmt.Printf("[-]Config reloaded: %d added, %d removed, %d restarted.\n",
+			len(report.Added), len(report.Removed), len(report.Restarted))
+		for name, reason := range report.Failed {
+			fmt.Printf("[x]Core '%s' failed to apply: %s\n", name, reason)
+		}
+	}
+	mws.Broadcast("", "config_reload", report)
+}
+
+func diffCredentials(old, next []*Credential) []string {
+	var changed []string
+	for _, nc := range next {
+		index := slices.IndexFunc(old, func(c *Credential) bool {
+			return c.Name == nc.Name
+		})
+		if index == -1 || !reflect.DeepEqual(old[index], nc) {
+			changed = append(changed, nc.Name)
+		}
+	}
+	for _, oc := range old {
+		if !slices.ContainsFunc(next, func(c *Credential) bool { return c.Name == oc.Name }) {
+			changed = append(changed, oc.Name)
+		}
+	}
+	return changed
+}
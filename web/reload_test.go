@@ -0,0 +1,226 @@
+package web
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+func TestApplyConfig(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	cfg := &runtime.Config{
+		Credentials: map[string]runtime.CredentialConfig{"c": {}},
+		Cores: map[string]runtime.CoreConfig{
+			"a": testCore("127.0.0.1:9001"),
+			"b": testCore("127.0.0.1:9002"),
+		},
+	}
+	report := mws.ApplyConfig(cfg)
+	if !report.Success || !slices.Equal(report.Added, []string{"a", "b"}) {
+		t.Fatalf("first apply = %+v", report)
+	}
+	dialer.next(t)
+	dialer.next(t)
+	oldA := loadCore(t, mws, "a")
+
+	changed := testCore("127.0.0.1:9003")
+	next := &runtime.Config{
+		Credentials: cfg.Credentials,
+		Cores: map[string]runtime.CoreConfig{
+			"a": changed,
+			"c": testCore("127.0.0.1:9004"),
+		},
+	}
+	report = mws.ApplyConfig(next)
+	if !report.Success ||
+		!slices.Equal(report.Added, []string{"c"}) ||
+		!slices.Equal(report.Removed, []string{"b"}) ||
+		!slices.Equal(report.Restarted, []string{"a"}) {
+		t.Fatalf("second apply = %+v", report)
+	}
+	if oldA.Context.Err() == nil {
+		t.Error("restarted core kept its old context")
+	}
+	if got := loadCore(t, mws, "a").Address(); got != "127.0.0.1:9003" {
+		t.Errorf("restarted core address = %s", got)
+	}
+	if _, ok := mws.cores.Load("b"); ok {
+		t.Error("removed core still registered")
+	}
+}
+
+// 新配置无法构建核心时，旧核心不受影响继续运行
+func TestApplyConfigKeepsCoreOnFailedRestart(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	cfg := &runtime.Config{
+		Credentials: map[string]runtime.CredentialConfig{"c": {}},
+		Cores:       map[string]runtime.CoreConfig{"a": testCore("127.0.0.1:9001")},
+	}
+	mws.ApplyConfig(cfg)
+	dialer.next(t)
+	oldA := loadCore(t, mws, "a")
+
+	changed := testCore("127.0.0.1:9002")
+	changed.Credential = "missing"
+	report := mws.ApplyConfig(&runtime.Config{
+		Credentials: cfg.Credentials,
+		Cores:       map[string]runtime.CoreConfig{"a": changed},
+	})
+	if report.Success || len(report.Restarted) != 0 || report.Failed["a"] == "" {
+		t.Fatalf("apply = %+v, want a failed", report)
+	}
+	if loadCore(t, mws, "a") != oldA || oldA.Context.Err() != nil {
+		t.Error("failed restart stopped the running core")
+	}
+}
+
+func TestApplyConfigReport(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(cfg *runtime.Config)
+		added     []string
+		removed   []string
+		restarted []string
+		failed    []string
+	}{
+		{"unchanged", func(cfg *runtime.Config) {}, nil, nil, nil, nil},
+		{"core added", func(cfg *runtime.Config) { cfg.Cores["c"] = testCore("127.0.0.1:9003") }, []string{"c"}, nil, nil, nil},
+		{"core removed", func(cfg *runtime.Config) { delete(cfg.Cores, "b") }, nil, []string{"b"}, nil, nil},
+		{"interval changed", func(cfg *runtime.Config) {
+			a := cfg.Cores["a"]
+			a.Interval = "2s"
+			cfg.Cores["a"] = a
+		}, nil, nil, []string{"a"}, nil},
+		{"health check changed", func(cfg *runtime.Config) {
+			b := cfg.Cores["b"]
+			b.HealthCheck.MinUsageRate = 0.5
+			cfg.Cores["b"] = b
+		}, nil, nil, []string{"b"}, nil},
+		{"credential changed", func(cfg *runtime.Config) {
+			cfg.Credentials["c"] = runtime.CredentialConfig{Path: "other"}
+		}, nil, nil, []string{"a", "b"}, nil},
+		{"unknown credential", func(cfg *runtime.Config) {
+			d := testCore("127.0.0.1:9004")
+			d.Credential = "missing"
+			cfg.Cores["d"] = d
+		}, nil, nil, nil, []string{"d"}},
+	}
+	config := func() *runtime.Config {
+		return &runtime.Config{
+			Credentials: map[string]runtime.CredentialConfig{"c": {}},
+			Cores: map[string]runtime.CoreConfig{
+				"a": testCore("127.0.0.1:9001"),
+				"b": testCore("127.0.0.1:9002"),
+			},
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mws, dialer := newTestServer(t)
+			mws.ApplyConfig(config())
+			dialer.next(t)
+			dialer.next(t)
+
+			next := config()
+			tt.change(next)
+			report := mws.ApplyConfig(next)
+			failed := slices.Sorted(maps.Keys(report.Failed))
+			if !slices.Equal(report.Added, tt.added) || !slices.Equal(report.Removed, tt.removed) ||
+				!slices.Equal(report.Restarted, tt.restarted) || !slices.Equal(failed, tt.failed) || report.Success != (len(tt.failed) == 0) {
+				t.Errorf("apply = %+v", report)
+			}
+			// 新增与重启的核心各建立一次连接
+			for range len(tt.added) + len(tt.restarted) {
+				dialer.next(t)
+			}
+			for name := range next.Cores {
+				if _, ok := mws.cores.Load(name); ok == slices.Contains(tt.failed, name) {
+					t.Errorf("core %s registered %v", name, ok)
+				}
+			}
+		})
+	}
+}
+
+// 核心未能及时停止时仍按新配置重新添加，并且只在 removed 中出现一次
+func TestApplyConfigSlowStop(t *testing.T) {
+	timeout := removeTimeout
+	removeTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { removeTimeout = timeout })
+
+	mws, dialer := newTestServer(t)
+	dialer.hang(t)
+	cfg := &runtime.Config{
+		Credentials: map[string]runtime.CredentialConfig{"c": {}},
+		Cores: map[string]runtime.CoreConfig{
+			"a": testCore("127.0.0.1:9001"),
+			"b": testCore("127.0.0.1:9002"),
+		},
+	}
+	mws.ApplyConfig(cfg)
+	dialer.next(t)
+	dialer.next(t)
+
+	changed := testCore("127.0.0.1:9003")
+	report := mws.ApplyConfig(&runtime.Config{
+		Credentials: cfg.Credentials,
+		Cores:       map[string]runtime.CoreConfig{"a": changed},
+	})
+	if !report.Success || len(report.Failed) != 0 ||
+		!slices.Equal(report.Removed, []string{"b"}) || !slices.Equal(report.Restarted, []string{"a"}) {
+		t.Fatalf("apply = %+v", report)
+	}
+	if got := dialer.next(t).address; got != "127.0.0.1:9003" {
+		t.Errorf("restarted core connected to %s", got)
+	}
+	if got := loadCore(t, mws, "a").Address(); got != "127.0.0.1:9003" {
+		t.Errorf("restarted core address = %s", got)
+	}
+	if _, ok := mws.cores.Load("b"); ok {
+		t.Error("removed core still registered")
+	}
+}
+
+func TestApplyConfigCredentialChangeRestartsCores(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	cfg := &runtime.Config{
+		Credentials: map[string]runtime.CredentialConfig{"c": {}},
+		Cores:       map[string]runtime.CoreConfig{"a": testCore("127.0.0.1:9001")},
+	}
+	mws.ApplyConfig(cfg)
+	dialer.next(t)
+
+	next := &runtime.Config{
+		Credentials: map[string]runtime.CredentialConfig{"c": {Path: "other"}},
+		Cores:       cfg.Cores,
+	}
+	if report := mws.ApplyConfig(next); !slices.Equal(report.Restarted, []string{"a"}) {
+		t.Fatalf("apply = %+v, want a restarted", report)
+	}
+}
+
+func TestReloadKeepsCoresOnError(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	messages := listen(t, mws)
+	mws.ApplyConfig(&runtime.Config{
+		Credentials: map[string]runtime.CredentialConfig{"c": {}},
+		Cores:       map[string]runtime.CoreConfig{"a": testCore("127.0.0.1:9001")},
+	})
+	dialer.next(t)
+
+	mws.Reload(nil, errors.New("parse error"))
+	loadCore(t, mws, "a")
+	for msg := range messages {
+		if msg.Type == "config_reload" {
+			if !containsJSON(msg.Data, `"success":false`) {
+				t.Errorf("config_reload = %s, want failure", msg.Data)
+			}
+			return
+		}
+	}
+	t.Fatal("no config_reload message")
+}
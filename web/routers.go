@@ -5,7 +5,11 @@ import (
 	"io"
 	"io/fs"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/B9O2/mtmonitor/core"
 	"github.com/B9O2/mtmonitor/runtime"
 	"github.com/gin-gonic/gin"
 )
@@ -53,6 +57,9 @@ func (mws *MonitorWebServer) SetRoutes(subFS fs.FS) {
 		mws.HandleWebSocket(c.Writer, c.Request)
 	})
 
+	// Prometheus 抓取端点
+	mws.render.GET("/metrics", mws.handleMetrics)
+
 	mws.setApiRoutes()
 }
 
@@ -62,21 +69,7 @@ func (mws *MonitorWebServer) setApiRoutes() {
 	{
 		// 获取所有cores列表
 		apiGroup.GET("/cores", func(c *gin.Context) {
-			var coreList []map[string]interface{}
-
-			mws.cores.Range(func(key, value interface{}) bool {
-				name := key.(string)
-				core := value.(*MTCore)
-				coreList = append(coreList, map[string]interface{}{
-					"name":     name,
-					"host":     core.Host,
-					"port":     core.Port,
-					"interval": core.Interval,
-				})
-				return true
-			})
-
-			c.JSON(http.StatusOK, coreList)
+			c.JSON(http.StatusOK, mws.coreList())
 		})
 
 		// 获取特定core的详情
@@ -90,10 +83,14 @@ func (mws *MonitorWebServer) setApiRoutes() {
 
 			core := value.(*MTCore)
 			c.JSON(http.StatusOK, gin.H{
-				"name":     name,
-				"host":     core.Host,
-				"port":     core.Port,
-				"interval": core.Interval,
+				"name":      name,
+				"target":    core.Address(),
+				"host":      core.Host,
+				"port":      core.Port,
+				"interval":  core.Interval,
+				"status":    core.Status(),
+				"health":    core.collector.Health(),
+				"effective": mws.redactedCore(name, *core.CoreConfig),
 			})
 		})
 
@@ -101,10 +98,12 @@ func (mws *MonitorWebServer) setApiRoutes() {
 		apiGroup.POST("/cores", func(c *gin.Context) {
 			var req struct {
 				Name     string `json:"name" binding:"required"`
-				Host     string `json:"host" binding:"required"`
-				Port     int    `json:"port" binding:"required"`
-				Interval string `json:"interval" binding:"required"`
+				Target   string `json:"target"`
+				Host     string `json:"host"`
+				Port     int    `json:"port"`
+				Interval string `json:"interval"`
 				CredName string `json:"credential_name"`
+				Extends  string `json:"extends"`
 			}
 
 			if err := c.ShouldBindJSON(&req); err != nil {
@@ -112,41 +111,226 @@ func (mws *MonitorWebServer) setApiRoutes() {
 				return
 			}
 
-			err := mws.AddCore(req.Name, runtime.CoreConfig{
+			cfg := runtime.CoreConfig{
+				Extends:    req.Extends,
+				Target:     req.Target,
 				Host:       req.Host,
 				Port:       req.Port,
 				Interval:   req.Interval,
 				Credential: req.CredName,
-			})
+			}
+			// 添加与持久化期间不允许重载配置，否则重载会按旧配置移除刚添加的核心
+			mws.reloadLock.Lock()
+			defer mws.reloadLock.Unlock()
+			effective, err := mws.resolveCore(req.Name, cfg)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if errs := mws.validateCore(req.Name, effective); len(errs) > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errs.Error(), "errors": errs})
+				return
+			}
+			err = mws.AddCore(req.Name, effective)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
 
+			if mws.persister != nil {
+				revision, err := mws.persister.PutCore(req.Name, cfg)
+				if err != nil {
+					// 持久化失败时回滚，保持内存与文件一致
+					if rbErr := mws.removeCore(req.Name); rbErr != nil {
+						err = fmt.Errorf("%w; rollback failed: %v", err, rbErr)
+					}
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusCreated, gin.H{"message": "Core添加成功", "revision": revision})
+				return
+			}
+
+			mws.rememberAPICore(req.Name, cfg)
 			c.JSON(http.StatusCreated, gin.H{"message": "Core添加成功"})
 		})
 
-		// 删除现有的core
+		// 删除现有的core，先持久化再停止核心，持久化失败时核心保持运行
 		apiGroup.DELETE("/cores/:name", func(c *gin.Context) {
 			name := c.Param("name")
-			err := mws.RemoveCore(name)
+			mws.reloadLock.Lock()
+			defer mws.reloadLock.Unlock()
+			if _, ok := mws.cores.Load(name); !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("core with name %s does not exist", name)})
+				return
+			}
+
+			resp := gin.H{"message": "Core删除成功"}
+			if mws.persister != nil {
+				revision, err := mws.persister.DeleteCore(name)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				resp["revision"] = revision
+			}
+
+			mws.forgetAPICore(name)
+			if err := mws.removeCore(name); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, resp)
+		})
+
+		// 获取core的健康问题，state 可选 open、resolved，缺省返回全部；severity 为最低严重程度
+		apiGroup.GET("/cores/:name/issues", func(c *gin.Context) {
+			name := c.Param("name")
+			value, ok := mws.cores.Load(name)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Core not found"})
+				return
+			}
+
+			state := core.IssueState(c.Query("state"))
+			switch state {
+			case "", core.IssueOpen, core.IssueResolved:
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid state %q, expected open or resolved", state)})
+				return
+			}
+			severity, err := core.ParseSeverity(c.Query("severity"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			c.JSON(http.StatusOK, value.(*MTCore).collector.Issues(state, severity))
+		})
+
+		// 获取core的指标历史，from/to 为 RFC3339、Unix 秒或相对当前的时长（如 -15m），
+		// fields 为逗号分隔的字段，step 为降采样步长（如 10s）
+		apiGroup.GET("/cores/:name/history", func(c *gin.Context) {
+			name := c.Param("name")
+			value, ok := mws.cores.Load(name)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Core not found"})
+				return
+			}
+
+			now := time.Now()
+			query := core.HistoryQuery{}
+			var err error
+			if query.To, err = parseQueryTime(c.Query("to"), now, now); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+				return
+			}
+			if query.From, err = parseQueryTime(c.Query("from"), now, time.Time{}); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+				return
+			}
+			if step := c.Query("step"); step != "" {
+				if query.Step, err = time.ParseDuration(step); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid step: " + err.Error()})
+					return
+				}
+			}
+			if fields := c.Query("fields"); fields != "" {
+				query.Fields = strings.Split(fields, ",")
+			}
+
+			series, err := value.(*MTCore).collector.History().Query(query)
 			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, series)
+		})
+
+		// 获取core此前各次运行（重启之前）的统计
+		apiGroup.GET("/cores/:name/runs", func(c *gin.Context) {
+			name := c.Param("name")
+			value, ok := mws.cores.Load(name)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Core not found"})
+				return
+			}
+
+			c.JSON(http.StatusOK, value.(*MTCore).collector.Runs())
+		})
+
+		// 立即重连，跳过退避等待
+		apiGroup.POST("/cores/:name/reconnect", func(c *gin.Context) {
+			name := c.Param("name")
+			value, ok := mws.cores.Load(name)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Core not found"})
 				return
 			}
 
-			c.JSON(http.StatusOK, gin.H{"message": "Core删除成功"})
+			value.(*MTCore).Reconnect()
+			c.JSON(http.StatusAccepted, gin.H{"message": "Core重连中"})
+		})
+
+		// 获取当前生效的配置及持久化版本
+		apiGroup.GET("/config", func(c *gin.Context) {
+			// 脱敏读取的敏感键记录与 API 添加核心时写入的是同一份，需在锁内完成
+			mws.reloadLock.Lock()
+			cfg := runtime.Config{}
+			if mws.config != nil {
+				cfg = *mws.config
+			}
+			cfg.Cores = mws.runningCores()
+			redacted := cfg.Redacted()
+			mws.reloadLock.Unlock()
+
+			resp := gin.H{
+				"config":   redacted,
+				"mode":     "",
+				"revision": 0,
+			}
+			if mws.persister != nil {
+				state, err := mws.persister.State()
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				resp["mode"] = mws.persister.Mode()
+				resp["revision"] = state.Revision
+				resp["updated_at"] = state.UpdatedAt
+			}
+
+			c.JSON(http.StatusOK, resp)
 		})
 
 		// 获取所有Credentials列表
 		apiGroup.GET("/credentials", func(c *gin.Context) {
 			var credList []string
+			mws.credLock.RLock()
 			for _, cred := range mws.credentials {
 				credList = append(credList, cred.Name)
 			}
+			mws.credLock.RUnlock()
 
 			c.JSON(http.StatusOK, credList)
 		},
 		)
 	}
 }
+
+// parseQueryTime 解析查询参数中的时间：RFC3339、Unix 秒或相对当前时间的时长（如 -15m），为空时返回 def
+func parseQueryTime(value string, now time.Time, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a RFC3339 time, unix timestamp or duration", value)
+}
@@ -0,0 +1,354 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/B9O2/mtmonitor/runtime"
+)
+
+// doJSON 向服务发送请求并返回响应
+func doJSON(t *testing.T, mws *MonitorWebServer, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mws.render.ServeHTTP(w, req)
+	return w
+}
+
+// API 添加的核心按生效配置校验，与配置文件中的核心一致
+func TestAddCoreValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults runtime.CoreConfig
+		body     map[string]any
+		code     int
+	}{
+		{"valid", runtime.CoreConfig{}, map[string]any{"interval": "1s"}, http.StatusCreated},
+		{"interval from defaults", runtime.CoreConfig{Interval: "1s"}, nil, http.StatusCreated},
+		{"invalid defaults", runtime.CoreConfig{HealthCheck: runtime.HealthCheckConfig{MinUsageRate: 2}}, map[string]any{"interval": "1s"}, http.StatusBadRequest},
+		{"bad interval", runtime.CoreConfig{}, map[string]any{"interval": "soon"}, http.StatusBadRequest},
+		{"interval from env", runtime.CoreConfig{}, map[string]any{"interval": "${MTMONITOR_TEST_UNSET:-1s}"}, http.StatusCreated},
+		{"unset env", runtime.CoreConfig{}, map[string]any{"interval": "${MTMONITOR_TEST_UNSET}"}, http.StatusBadRequest},
+		{"unknown credential", runtime.CoreConfig{}, map[string]any{"interval": "1s", "credential_name": "nope"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mws, _ := newTestServer(t)
+			mws.ApplyConfig(&runtime.Config{Credentials: map[string]runtime.CredentialConfig{"c": {}}, Defaults: tt.defaults})
+			body := map[string]any{"name": "api", "host": "127.0.0.1", "port": 9001, "credential_name": "c"}
+			for key, value := range tt.body {
+				body[key] = value
+			}
+			w := doJSON(t, mws, http.MethodPost, "/api/cores", body)
+			if w.Code != tt.code {
+				t.Fatalf("POST status = %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			if _, ok := mws.cores.Load("api"); ok != (tt.code == http.StatusCreated) {
+				t.Errorf("core registered = %v after status %d", ok, w.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteCore(t *testing.T) {
+	tests := []struct {
+		name      string
+		persister func(t *testing.T) *runtime.Persister
+		core      string
+		code      int
+		removed   bool
+	}{
+		{"no persistence", nil, "a", http.StatusOK, true},
+		{"unknown core", nil, "missing", http.StatusNotFound, false},
+		{"persisted", func(t *testing.T) *runtime.Persister {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte("[cores.a]\nhost = \"127.0.0.1\"\nport = 9001\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			p, _ := runtime.NewPersister(path, runtime.PersistenceConfig{Mode: runtime.PersistModeState})
+			return p
+		}, "a", http.StatusOK, true},
+		{"persistence fails", func(t *testing.T) *runtime.Persister {
+			// 配置文件不存在，写入失败
+			p, _ := runtime.NewPersister(filepath.Join(t.TempDir(), "missing.toml"), runtime.PersistenceConfig{Mode: runtime.PersistModeConfig})
+			return p
+		}, "a", http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mws, dialer := newTestServer(t)
+			if tt.persister != nil {
+				mws.SetPersister(tt.persister(t))
+			}
+			if err := mws.AddCore("a", testCore("127.0.0.1:9001")); err != nil {
+				t.Fatal(err)
+			}
+			conn := dialer.next(t)
+			mtCore := loadCore(t, mws, "a")
+
+			w := doJSON(t, mws, http.MethodDelete, "/api/cores/"+tt.core, nil)
+			if w.Code != tt.code {
+				t.Fatalf("DELETE status = %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			_, registered := mws.cores.Load("a")
+			if registered == tt.removed {
+				t.Errorf("core registered = %v after DELETE", registered)
+			}
+			if tt.removed {
+				if conn.ctx.Err() == nil || mtCore.Status().State != StateStopped {
+					t.Error("deleted core still connected")
+				}
+			} else if conn.ctx.Err() != nil {
+				t.Error("core stopped although DELETE failed")
+			}
+		})
+	}
+}
+
+func TestAPICoreSurvivesReload(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	cfg := &runtime.Config{
+		Credentials: map[string]runtime.CredentialConfig{"c": {}},
+		Cores:       map[string]runtime.CoreConfig{"file": testCore("127.0.0.1:9001")},
+	}
+	mws.ApplyConfig(cfg)
+	dialer.next(t)
+
+	w := doJSON(t, mws, http.MethodPost, "/api/cores", map[string]any{
+		"name": "api", "host": "127.0.0.1", "port": 9002, "interval": "1s", "credential_name": "c",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d: %s", w.Code, w.Body)
+	}
+	dialer.next(t)
+	apiCore := loadCore(t, mws, "api")
+
+	report := mws.ApplyConfig(cfg)
+	if len(report.Removed) != 0 || len(report.Restarted) != 0 {
+		t.Fatalf("reload = %+v, want API core kept", report)
+	}
+	if loadCore(t, mws, "api") != apiCore {
+		t.Error("API core replaced by reload")
+	}
+
+	// 配置文件定义了同名核心后以配置文件为准
+	cfg = &runtime.Config{
+		Credentials: cfg.Credentials,
+		Cores: map[string]runtime.CoreConfig{
+			"file": cfg.Cores["file"],
+			"api":  testCore("127.0.0.1:9003"),
+		},
+	}
+	mws.ApplyConfig(cfg)
+	if got := loadCore(t, mws, "api").Address(); got != "127.0.0.1:9003" {
+		t.Errorf("api core address = %s, want config file's", got)
+	}
+	delete(cfg.Cores, "api")
+	if report := mws.ApplyConfig(cfg); len(report.Removed) != 1 || report.Removed[0] != "api" {
+		t.Errorf("reload = %+v, want api removed once defined by the config file", report)
+	}
+
+	// 通过 API 删除后重载不会恢复
+	doJSON(t, mws, http.MethodPost, "/api/cores", map[string]any{
+		"name": "api2", "host": "127.0.0.1", "port": 9004, "interval": "1s", "credential_name": "c",
+	})
+	if w := doJSON(t, mws, http.MethodDelete, "/api/cores/api2", nil); w.Code != http.StatusOK {
+		t.Fatalf("DELETE status = %d", w.Code)
+	}
+	if report := mws.ApplyConfig(cfg); len(report.Added) != 0 {
+		t.Errorf("reload = %+v, deleted API core came back", report)
+	}
+}
+
+// API 增删核心与配置重载同时进行时，重载不会撤销 API 的修改
+func TestAPICoreConcurrentReload(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	cfg := &runtime.Config{Credentials: map[string]runtime.CredentialConfig{"c": {}}}
+	mws.ApplyConfig(cfg)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-dialer.conns:
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				mws.ApplyConfig(cfg)
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	for i := range 50 {
+		name := fmt.Sprintf("api%d", i)
+		w := doJSON(t, mws, http.MethodPost, "/api/cores", map[string]any{
+			"name": name, "host": "127.0.0.1", "port": 9001, "interval": "1s", "credential_name": "c",
+		})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST %s status = %d: %s", name, w.Code, w.Body)
+		}
+		if _, ok := mws.cores.Load(name); !ok {
+			t.Fatalf("%s removed by a concurrent reload", name)
+		}
+		if w := doJSON(t, mws, http.MethodDelete, "/api/cores/"+name, nil); w.Code != http.StatusOK {
+			t.Fatalf("DELETE %s status = %d: %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+// 读取配置与 API 添加继承敏感值的核心同时进行，需在 -race 下运行
+func TestConfigReadDuringAPIAdd(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "interval"), []byte("1s"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	content := fmt.Sprintf("[credentials.c]\n\n[defaults]\ninterval = \"file:%s\"\n\n[defaults.health_check]\nmax_working_interval_times = 3\n",
+		filepath.ToSlash(filepath.Join(dir, "interval")))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := runtime.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mws, dialer := newTestServer(t)
+	mws.ApplyConfig(cfg)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-dialer.conns:
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if w := doJSON(t, mws, http.MethodGet, "/api/config", nil); w.Code != http.StatusOK {
+					t.Errorf("GET /api/config status = %d", w.Code)
+					return
+				}
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	for i := range 20 {
+		name := fmt.Sprintf("api%d", i)
+		w := doJSON(t, mws, http.MethodPost, "/api/cores", map[string]any{
+			"name": name, "host": "127.0.0.1", "port": 9001 + i, "credential_name": "c",
+		})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST %s status = %d: %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestCoreListTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  runtime.CoreConfig
+		want string
+	}{
+		{"host and port", runtime.CoreConfig{Host: "::1", Port: 9000}, "[::1]:9000"},
+		{"unix socket", runtime.CoreConfig{Target: "/run/mt.sock"}, "unix:///run/mt.sock"},
+		{"dns", runtime.CoreConfig{Target: "dns:///svc:9000"}, "dns:///svc:9000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mws, dialer := newTestServer(t)
+			messages := listen(t, mws)
+			if msg := <-messages; msg.Type != "cores" || string(msg.Data) != "[]" {
+				t.Fatalf("first message = %s %s, want empty core list", msg.Type, msg.Data)
+			}
+
+			cc := testCore("")
+			cc.Target, cc.Host, cc.Port = tt.cfg.Target, tt.cfg.Host, tt.cfg.Port
+			if err := mws.AddCore("a", cc); err != nil {
+				t.Fatal(err)
+			}
+			dialer.next(t)
+
+			var list, status bool
+			for msg := range messages {
+				var payload struct {
+					Target string `json:"target"`
+				}
+				switch msg.Type {
+				case "cores":
+					var cores []coreSummary
+					if err := json.Unmarshal(msg.Data, &cores); err != nil || len(cores) != 1 || cores[0].Target != tt.want {
+						t.Fatalf("cores message = %s", msg.Data)
+					}
+					list = true
+				case "core_status":
+					if err := json.Unmarshal(msg.Data, &payload); err != nil || payload.Target != tt.want {
+						t.Fatalf("core_status message = %s", msg.Data)
+					}
+					status = true
+				}
+				if list && status {
+					break
+				}
+			}
+			if !list || !status {
+				t.Fatal("websocket closed before cores and core_status messages")
+			}
+
+			w := doJSON(t, mws, http.MethodGet, "/api/cores", nil)
+			var cores []coreSummary
+			if err := json.Unmarshal(w.Body.Bytes(), &cores); err != nil || len(cores) != 1 || cores[0].Target != tt.want {
+				t.Errorf("GET /api/cores = %s", w.Body)
+			}
+		})
+	}
+}
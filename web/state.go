@@ -0,0 +1,160 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ConnState string
+
+const (
+	StateIdle       ConnState = "idle"       // 已创建，尚未开始连接
+	StateConnecting ConnState = "connecting" // 正在建立连接与数据流
+	StateStreaming  ConnState = "streaming"  // 数据流已建立
+	StateBackoff    ConnState = "backoff"    // 连接失败，等待重连
+	StateFailed     ConnState = "failed"     // 无法恢复的错误，不再重连
+	StateStopped    ConnState = "stopped"    // 核心已被移除
+)
+
+var ConnStates = []ConnState{StateIdle, StateConnecting, StateStreaming, StateBackoff, StateFailed, StateStopped}
+
+// CoreStatus 核心连接状态快照，通过 core_status 消息推送
+type CoreStatus struct {
+	State          ConnState  `json:"state"`
+	Target         string     `json:"target"`
+	LastError      string     `json:"last_error,omitempty"`
+	ErrorKind      string     `json:"error_kind,omitempty"`
+	Attempts       uint       `json:"attempts"`
+	ConnectedSince *time.Time `json:"connected_since,omitempty"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	NextRetryAt    *time.Time `json:"next_retry_at,omitempty"`
+	ChangedAt      time.Time  `json:"changed_at"`
+}
+
+type connTracker struct {
+	lock     sync.Mutex
+	status   CoreStatus
+	onChange func(CoreStatus)
+}
+
+func (ct *connTracker) Snapshot() CoreStatus {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	return ct.status
+}
+
+func (ct *connTracker) transition(state ConnState, err error) {
+	ct.transitionAt(state, err, nil)
+}
+
+func (ct *connTracker) backoff(err error, next time.Time) {
+	ct.transitionAt(StateBackoff, err, &next)
+}
+
+func (ct *connTracker) transitionAt(state ConnState, err error, next *time.Time) {
+	ct.lock.Lock()
+	now := time.Now()
+	ct.status.State = state
+	ct.status.ChangedAt = now
+	ct.status.NextRetryAt = next
+	switch state {
+	case StateConnecting:
+		ct.status.Attempts++
+	case StateStreaming:
+		ct.status.ConnectedSince = &now
+	default:
+		ct.status.ConnectedSince = nil
+	}
+	if err != nil {
+		ct.status.LastError = err.Error()
+		ct.status.ErrorKind = classifyError(err)
+	}
+	snapshot := ct.status
+	ct.lock.Unlock()
+
+	if ct.onChange != nil {
+		ct.onChange(snapshot)
+	}
+}
+
+// lastActivity 返回最近一次收到消息的时间，尚未收到消息时为建立连接的时间
+func (ct *connTracker) lastActivity() time.Time {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	last := ct.status.ChangedAt
+	if ct.status.ConnectedSince != nil {
+		last = *ct.status.ConnectedSince
+	}
+	if ct.status.LastMessageAt != nil && ct.status.LastMessageAt.After(last) {
+		last = *ct.status.LastMessageAt
+	}
+	return last
+}
+
+func (ct *connTracker) attempts() uint {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	return ct.status.Attempts
+}
+
+func (ct *connTracker) resetAttempts() {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	ct.status.Attempts = 0
+}
+
+// recordError 记录数据流中断的原因，不改变状态
+func (ct *connTracker) recordError(err error) {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	ct.status.LastError = err.Error()
+	ct.status.ErrorKind = classifyError(err)
+}
+
+// touch 记录收到消息的时间，首条消息表示连接健康，重置尝试次数
+func (ct *connTracker) touch() {
+	ct.lock.Lock()
+	defer ct.lock.Unlock()
+	now := time.Now()
+	if ct.status.LastMessageAt == nil || ct.status.ConnectedSince == nil || ct.status.LastMessageAt.Before(*ct.status.ConnectedSince) {
+		ct.status.Attempts = 0
+	}
+	ct.status.LastMessageAt = &now
+}
+
+func classifyError(err error) string {
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded:
+		return "timeout"
+	case status.Code(err) == codes.Unauthenticated || status.Code(err) == codes.PermissionDenied:
+		return "auth"
+	case strings.Contains(msg, "tls:") || strings.Contains(msg, "x509") || strings.Contains(msg, "certificate"):
+		return "tls"
+	case strings.Contains(msg, "connection refused"):
+		return "refused"
+	case strings.Contains(msg, "no such host") || strings.Contains(msg, "no such file"):
+		return "unreachable"
+	case status.Code(err) == codes.Unavailable:
+		return "unavailable"
+	default:
+		return "error"
+	}
+}
+
+func newConnTracker(target string, onChange func(CoreStatus)) *connTracker {
+	return &connTracker{
+		status: CoreStatus{
+			State:     StateIdle,
+			Target:    target,
+			ChangedAt: time.Now(),
+		},
+		onChange: onChange,
+	}
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+	}{
+		{context.DeadlineExceeded, "timeout"},
+		{fmt.Errorf("dial: %w", context.DeadlineExceeded), "timeout"},
+		{status.Error(codes.DeadlineExceeded, "slow"), "timeout"},
+		{status.Error(codes.Unauthenticated, "bad token"), "auth"},
+		{status.Error(codes.PermissionDenied, "denied"), "auth"},
+		{errors.New("tls: handshake failure"), "tls"},
+		{errors.New("x509: certificate signed by unknown authority"), "tls"},
+		{errors.New("dial tcp 127.0.0.1:9000: connect: connection refused"), "refused"},
+		{errors.New("lookup core.internal: no such host"), "unreachable"},
+		{errors.New("dial unix /run/core.sock: connect: no such file or directory"), "unreachable"},
+		{status.Error(codes.Unavailable, "transport is closing"), "unavailable"},
+		{errors.New("boom"), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.kind {
+				t.Errorf("classifyError() = %q, want %q", got, tt.kind)
+			}
+		})
+	}
+}
+
+func TestConnTracker(t *testing.T) {
+	refused := errors.New("connection refused")
+	steps := []struct {
+		name      string
+		apply     func(ct *connTracker)
+		state     ConnState
+		attempts  uint
+		connected bool
+		nextRetry bool
+		lastError string
+		changes   int // 累计调用 onChange 的次数
+	}{
+		{"created", func(ct *connTracker) {}, StateIdle, 0, false, false, "", 0},
+		{"connecting", func(ct *connTracker) { ct.transition(StateConnecting, nil) }, StateConnecting, 1, false, false, "", 1},
+		{"refused", func(ct *connTracker) { ct.backoff(refused, time.Now().Add(time.Second)) }, StateBackoff, 1, false, true, "connection refused", 2},
+		{"retrying", func(ct *connTracker) { ct.transition(StateConnecting, nil) }, StateConnecting, 2, false, false, "connection refused", 3},
+		{"streaming", func(ct *connTracker) { ct.transition(StateStreaming, nil) }, StateStreaming, 2, true, false, "connection refused", 4},
+		{"first message resets attempts", func(ct *connTracker) { ct.touch() }, StateStreaming, 0, true, false, "connection refused", 4},
+		{"stream error recorded", func(ct *connTracker) { ct.recordError(errors.New("stream reset")) }, StateStreaming, 0, true, false, "stream reset", 4},
+		{"stopped", func(ct *connTracker) { ct.transition(StateStopped, nil) }, StateStopped, 0, false, false, "stream reset", 5},
+	}
+
+	changes := 0
+	var last CoreStatus
+	ct := newConnTracker("127.0.0.1:9000", func(status CoreStatus) {
+		changes++
+		last = status
+	})
+	for _, step := range steps {
+		step.apply(ct)
+		got := ct.Snapshot()
+		if got.State != step.state || got.Attempts != step.attempts || (got.ConnectedSince != nil) != step.connected ||
+			(got.NextRetryAt != nil) != step.nextRetry || got.LastError != step.lastError || got.Target != "127.0.0.1:9000" {
+			t.Errorf("%s: status = %+v", step.name, got)
+		}
+		if changes != step.changes {
+			t.Errorf("%s: onChange called %d times, want %d", step.name, changes, step.changes)
+		}
+		if changes > 0 && last.State != got.State {
+			t.Errorf("%s: last pushed state %s, want %s", step.name, last.State, got.State)
+		}
+	}
+}
+
+// 状态变化通过 WebSocket 推送，并在核心详情中返回
+func TestCoreStatusExposed(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	messages := listen(t, mws)
+	if err := mws.AddCore("a", testCore("127.0.0.1:9001")); err != nil {
+		t.Fatal(err)
+	}
+	dialer.next(t)
+	waitState(t, loadCore(t, mws, "a"), StateStreaming)
+
+	var states []ConnState
+	untilMessage(t, messages, func(msg wsMessage) bool {
+		if msg.Name != "a" || msg.Type != "core_status" {
+			return false
+		}
+		var s CoreStatus
+		if err := json.Unmarshal(msg.Data, &s); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, s.State)
+		return s.State == StateStreaming
+	})
+	if len(states) != 2 || states[0] != StateConnecting {
+		t.Errorf("pushed states %v, want [connecting streaming]", states)
+	}
+
+	w := doJSON(t, mws, http.MethodGet, "/api/cores/a", nil)
+	var detail struct {
+		Status CoreStatus `json:"status"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || detail.Status.State != StateStreaming || detail.Status.Target != "127.0.0.1:9001" || detail.Status.ConnectedSince == nil {
+		t.Errorf("GET /api/cores/a = %d %s", w.Code, w.Body)
+	}
+}
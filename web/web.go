@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -10,7 +11,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/B9O2/Multitasking"
 	"github.com/B9O2/NStruct/Shield"
 	monitor_core "github.com/B9O2/monitors/core"
 	"github.com/B9O2/monitors/monitor"
@@ -20,10 +20,7 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 )
 
@@ -32,127 +29,174 @@ type MTCore struct {
 	Context          context.Context
 	Cancel           context.CancelFunc
 	IntervalDuration time.Duration
+	address          string
+	conn             *connTracker
+	reconnectPolicy  runtime.ReconnectPolicy
+	reconnect        chan struct{}
+	wg               sync.WaitGroup // 连接循环及数据流相关的所有 goroutine
+	collector        *core.Collector
+}
+
+func (m *MTCore) Status() CoreStatus {
+	return m.conn.Snapshot()
+}
+
+var removeTimeout = 5 * time.Second
+
+// ErrStopTimeout 核心已经移除，但其 goroutine 未在 removeTimeout 内退出
+var ErrStopTimeout = errors.New("core did not stop in time")
+
+func (m *MTCore) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Reconnect 跳过等待立即重连；处于 failed 状态时重新开始连接
+func (m *MTCore) Reconnect() {
+	m.conn.resetAttempts()
+	select {
+	case m.reconnect <- struct{}{}:
+	default:
+	}
 }
 
 func (m *MTCore) Address() string {
-	return fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return m.address
 }
 
 type Credential struct {
 	Name string `json:"name"`
-	Path string `json:"path"`
+	runtime.CredentialConfig
 }
 
 func NewCredential(name string, cc runtime.CredentialConfig) *Credential {
 	return &Credential{
-		Name: name,
-		Path: cc.Path,
+		Name:             name,
+		CredentialConfig: cc,
 	}
 }
 
 func NewCredentials(cfg map[string]runtime.CredentialConfig) []*Credential {
 	credentials := make([]*Credential, 0, len(cfg))
 	for name, cc := range cfg {
+		if cc.Type == runtime.CredentialToken && !cc.TLSEnabled() {
+			fmt.Printf("[!]Credential '%s' sends its token in plaintext.\n", name)
+		}
 		credentials = append(credentials, NewCredential(name, cc))
 	}
 	return credentials
 }
 
-func HandleCore(mtCore *MTCore, certPath string) (chan *core.Metrics, chan *monitor.Events, error) {
-	var creds credentials.TransportCredentials
-	var err error
-	var opts []grpc.DialOption
+// ErrCredential 凭证无法使用（证书无法加载等），重连也无法恢复
+var ErrCredential = errors.New("invalid credential")
+
+// HandleCore 两个数据流都绑定在 ctx 上，ctx 取消后流立即中断，两个通道关闭后客户端随之关闭
+func HandleCore(ctx context.Context, mtCore *MTCore, cred *Credential) (chan *core.Metrics, chan *monitor.Events, error) {
 	metricsChan := make(chan *core.Metrics)
 	eventsChan := make(chan *monitor.Events)
 
-	if certPath != "" {
-		creds, err = credentials.NewClientTLSFromFile(certPath, "localhost")
-		if err != nil {
-			return nil, nil, err
-		}
-	} else {
-		creds = insecure.NewCredentials()
+	opts, err := cred.DialOptions()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w '%s': %s", ErrCredential, cred.Name, err)
 	}
 
-	opts = append(opts, grpc.WithTransportCredentials(creds))
-
 	mc, err := monitor_core.NewMonitorClient(mtCore.Address(), opts...)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// 获取状态流
-	statusStream, err := mc.StreamStatus(context.Background(), mtCore.IntervalDuration)
+	statusStream, err := mc.StreamStatus(ctx, mtCore.IntervalDuration)
 	if err != nil {
 		mc.Close()
 		return nil, nil, err
 	}
 
 	// 获取事件流
-	eventsStream, err := mc.StreamEvents(context.Background(), mtCore.IntervalDuration, -1)
+	eventsStream, err := mc.StreamEvents(ctx, mtCore.IntervalDuration, -1)
 	if err != nil {
 		mc.Close()
 		return nil, nil, err
 	}
 
-	// 处理状态流
+	var streams sync.WaitGroup
+	streams.Add(2)
+	mtCore.wg.Add(1)
 	go func() {
-		lastMetrics := &core.Metrics{}
-		loop := true
-		for loop {
-			select {
-			case <-mtCore.Context.Done():
-				loop = false
-				continue
-			default:
-			}
+		defer mtCore.wg.Done()
+		streams.Wait()
+		mc.Close()
+	}()
 
+	// 处理状态流
+	go func() {
+		defer streams.Done()
+		defer close(metricsChan)
+		mtCore.collector.Reset()
+		for {
 			s, err := statusStream.Receive()
 			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
 				if status.Code(err) != codes.Canceled &&
 					!(status.Code(err) == codes.Unavailable && strings.Contains(err.Error(), "error reading from server: EOF")) {
 					// 处理错误
+					mtCore.conn.recordError(err)
+				} else {
+					mtCore.conn.recordError(errors.New("status stream closed by server"))
 				}
-				break
+				return
+			}
+			mtCore.conn.touch()
+			metrics := mtCore.collector.Update(s)
+			select {
+			case metricsChan <- metrics:
+			case <-ctx.Done():
+				return
 			}
-			metrics := core.NewMetrics(s, lastMetrics, mtCore.IntervalDuration, mtCore.HealthCheck)
-			metricsChan <- metrics
-			lastMetrics = metrics
 		}
-
-		close(metricsChan)
-		mc.Close()
 	}()
 
 	// 处理事件流
 	go func() {
-		loop := true
-		for loop {
-			select {
-			case <-mtCore.Context.Done():
-				loop = false
-				continue
-			default:
-			}
-
+		defer streams.Done()
+		defer close(eventsChan)
+		for {
 			e, err := eventsStream.Receive()
 			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
 				if status.Code(err) != codes.Canceled &&
 					!(status.Code(err) == codes.Unavailable && strings.Contains(err.Error(), "error reading from server: EOF")) {
 					// 处理错误
-					fmt.Println("Error receiving events:", err)
+					mtCore.conn.recordError(err)
 				}
-				break
+				return
 			}
+			mtCore.conn.touch()
 			if e == nil {
 				e = &monitor.Events{
 					Logs: make([]string, 0),
 				}
 			}
-			eventsChan <- e
+			mtCore.collector.Observe(e)
+			select {
+			case eventsChan <- e:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(eventsChan)
-		mc.Close()
 	}()
 
 	return metricsChan, eventsChan, nil
@@ -165,87 +209,279 @@ type MonitorWebServer struct {
 	shield      *Shield.Shield
 	upgrader    websocket.Upgrader
 	credentials []*Credential
+	credLock    sync.RWMutex
+	reloadLock  sync.Mutex
+	config      *runtime.Config
+	persister   *runtime.Persister
+	store       *core.Store
+	exporter    *core.OTLPExporter
+	// apiCores 未启用持久化时通过 API 添加的核心（未解析的配置），重载配置时保留，进程退出后丢失
+	apiCores map[string]runtime.CoreConfig
+	// connect 建立到核心的连接，默认为 HandleCore，测试中替换为假的核心
+	connect func(ctx context.Context, mtCore *MTCore, cred *Credential) (chan *core.Metrics, chan *monitor.Events, error)
+}
+
+func (mws *MonitorWebServer) SetPersister(p *runtime.Persister) {
+	mws.persister = p
+}
+
+func (mws *MonitorWebServer) SetStore(s *core.Store) {
+	mws.store = s
+}
+
+func (mws *MonitorWebServer) SetExporter(e *core.OTLPExporter) {
+	mws.exporter = e
 }
 
 func (mws *MonitorWebServer) AddCore(name string, cfg runtime.CoreConfig) error {
-	if _, ok := mws.cores.Load(name); ok {
-		return fmt.Errorf("core with name %s already exists", name)
+	mtCore, cred, err := mws.newCore(name, cfg)
+	if err != nil {
+		return err
 	}
+	return mws.startCore(name, mtCore, cred)
+}
 
+// newCore 按配置构建核心，此时尚未占用名称，也没有启动任何 goroutine
+func (mws *MonitorWebServer) newCore(name string, cfg runtime.CoreConfig) (*MTCore, *Credential, error) {
+	mws.credLock.RLock()
 	index := slices.IndexFunc(mws.credentials, func(c *Credential) bool {
 		return c.Name == cfg.Credential
 	})
 	if index == -1 {
-		return fmt.Errorf("credential with name %s does not exist", cfg.Credential)
+		mws.credLock.RUnlock()
+		return nil, nil, fmt.Errorf("credential with name %s does not exist", cfg.Credential)
 	}
 	cred := mws.credentials[index]
+	mws.credLock.RUnlock()
+
+	address, err := cfg.Address()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	interval := time.Duration(0)
 	if i, err := time.ParseDuration(cfg.Interval); err != nil {
-		return err
+		return nil, nil, err
 	} else {
 		interval = i
 	}
 
+	policy, err := cfg.Reconnect.Policy()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collector, err := core.NewCollector(name, interval, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mws.store != nil {
+		collector.History().SetStore(mws.store.Core(name))
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
-	core := &MTCore{
+	mtCore := &MTCore{
 		CoreConfig:       &cfg,
 		Context:          ctx,
 		Cancel:           cancel,
 		IntervalDuration: interval,
+		address:          address,
+		reconnectPolicy:  policy,
+		reconnect:        make(chan struct{}, 1),
+		collector:        collector,
 	}
 
-	mws.cores.Store(name, core)
+	mtCore.conn = newConnTracker(address, func(status CoreStatus) {
+		// 同名核心被替换后，旧核心退出时的状态不再推送，避免覆盖新核心的状态
+		if current, ok := mws.cores.Load(name); ok && current != mtCore {
+			return
+		}
+		mws.Broadcast(name, "core_status", status)
+	})
+	return mtCore, cred, nil
+}
+
+// startCore 占用名称并启动核心
+func (mws *MonitorWebServer) startCore(name string, mtCore *MTCore, cred *Credential) error {
+	// 同名核心并发添加时只有一个能占用名称，失败的一方尚未启动任何 goroutine
+	if _, loaded := mws.cores.LoadOrStore(name, mtCore); loaded {
+		mtCore.Cancel()
+		return fmt.Errorf("core with name %s already exists", name)
+	}
+	mws.Broadcast("", "cores", mws.coreList())
 
+	mtCore.wg.Add(1)
 	go func() {
-		name := name
-		loop := true
-		for loop {
+		defer mtCore.wg.Done()
+		mws.runCore(name, mtCore, cred)
+	}()
+	return nil
+}
+
+func (mws *MonitorWebServer) runCore(name string, core *MTCore, cred *Credential) {
+	ctx := core.Context
+	loop := true
+	for loop {
+		select {
+		case <-ctx.Done():
+			loop = false
+			continue
+		default:
+		}
+		core.conn.transition(StateConnecting, nil)
+		//fmt.Printf("Starting core %s at %s with interval %s\n", name, core.Address(), interval)
+		connCtx, cancelConn := context.WithCancel(ctx)
+		tokenVersion := cred.TokenFileVersion() // 在连接前读取，连接期间轮换的令牌也会触发重连
+		metricsChan, eventsChan, err := mws.connect(connCtx, core, cred)
+		if err == nil {
+			fmt.Println("Core", name, "is running at", core.Address())
+			core.conn.transition(StateStreaming, nil)
+			err = mws.streamCore(name, core, cred, tokenVersion, metricsChan, eventsChan)
+		} else {
+			fmt.Printf("[%s]Error handling core: %v\n", name, err)
+		}
+		cancelConn()
+
+		select {
+		case <-ctx.Done():
+			loop = false
+			continue
+		default:
+		}
+		// 手动重连时直接重新连接，不经过退避等待
+		if errors.Is(err, errManualReconnect) {
+			continue
+		}
+
+		// 凭证错误或连续失败次数达到上限时进入 failed，只能通过手动重连恢复
+		attempts := core.conn.attempts()
+		policy := core.reconnectPolicy
+		if errors.Is(err, ErrCredential) || (policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts) {
+			core.conn.transition(StateFailed, err)
 			select {
+			case <-core.reconnect:
 			case <-ctx.Done():
 				loop = false
+			}
+			continue
+		}
+
+		//fmt.Printf("Core %s has been stopped\n", name)
+		delay := policy.Delay(attempts)
+		core.conn.backoff(err, time.Now().Add(delay))
+		timer := time.NewTimer(delay) // 指数退避后重连
+		select {
+		case <-timer.C:
+		case <-core.reconnect:
+		case <-ctx.Done():
+			loop = false
+		}
+		timer.Stop()
+	}
+	if mws.exporter != nil {
+		mws.exporter.Forget(name)
+	}
+	core.conn.transition(StateStopped, nil)
+}
+
+var errManualReconnect = errors.New("manual reconnect")
+
+// streamCore 超过 stale_intervals 个周期没有收到任何消息时视为数据流停滞，上报健康问题并返回错误以触发重连。
+// 数据流只在建立时携带令牌，令牌文件变化后立即重连以使用新令牌。
+func (mws *MonitorWebServer) streamCore(name string, mtCore *MTCore, cred *Credential, tokenVersion string, metricsChan chan *core.Metrics, eventsChan chan *monitor.Events) error {
+	staleAfter := time.Duration(mtCore.HealthCheck.StaleIntervals) * mtCore.IntervalDuration
+	if staleAfter <= 0 {
+		staleAfter = runtime.DefaultStaleIntervals * mtCore.IntervalDuration
+	}
+	watchdog := time.NewTicker(mtCore.IntervalDuration)
+	defer watchdog.Stop()
+
+	for {
+		select {
+		case metrics := <-metricsChan:
+			if metrics == nil {
+				fmt.Printf("Core %s metrics channel closed\n", name)
+				return nil
+			}
+
+			mws.Broadcast(name, "metrics", metrics)
+			if mws.exporter != nil {
+				mws.exporter.ExportMetrics(name, mtCore.Tags, metrics)
+			}
+			for _, event := range metrics.CoreEvents {
+				mws.Broadcast(name, event.Type, event.Data)
+			}
+		case events := <-eventsChan:
+			if events == nil {
+				fmt.Printf("Core %s events channel closed\n", name)
+				return nil
+			}
+
+			mws.Broadcast(name, "events", events)
+			if mws.exporter != nil {
+				mws.exporter.ExportLogs(name, mtCore.Tags, events)
+			}
+		case <-watchdog.C:
+			if version := cred.TokenFileVersion(); version != "" && version != tokenVersion {
+				fmt.Printf("[-]Core %s token file changed, reconnecting.\n", name)
+				return errManualReconnect
+			}
+			silence := time.Since(mtCore.conn.lastActivity())
+			if silence < staleAfter {
 				continue
-			default:
 			}
-			//fmt.Printf("Starting core %s at %s with interval %s\n", name, core.Address(), interval)
-			metricsChan, eventsChan, err := HandleCore(core, cred.Path)
-			if err == nil {
-				fmt.Println("Core", name, "is running at", core.Address())
-				loop := true
-				for loop {
-					select {
-					case metrics := <-metricsChan:
-						if metrics == nil {
-							fmt.Printf("Core %s metrics channel closed\n", name)
-							loop = false
-							break
-						}
-
-						mws.Broadcast(name, "metrics", metrics)
-					case events := <-eventsChan:
-						if events == nil {
-							fmt.Printf("Core %s events channel closed\n", name)
-							loop = false
-							break
-						}
-
-						mws.Broadcast(name, "events", events)
-					case <-ctx.Done():
-						Multitasking.TryClose(metricsChan)
-						Multitasking.TryClose(eventsChan)
-						loop = false
-
-					}
-				}
-			} else {
-				fmt.Printf("[%s]Error handling core: %v\n", name, err)
+			err := fmt.Errorf("no messages received for %s (%d intervals)", silence.Round(time.Millisecond), int(silence/mtCore.IntervalDuration))
+			mtCore.conn.recordError(err)
+			issue := core.HealthIssue{
+				Type:        core.IssueStreamStale,
+				Title:       "Stream Stale",
+				Description: fmt.Sprintf("Core %s stopped sending data: %s. Reconnecting.", name, err),
+				ThreadID:    -1,
+				Severity:    core.SeverityCritical,
+			}
+			issue, events := mtCore.collector.RaiseIssue(issue)
+			mws.Broadcast(name, "health_issue", issue)
+			for _, event := range events {
+				mws.Broadcast(name, event.Type, event.Data)
 			}
-			//fmt.Printf("Core %s has been stopped\n", name)
-			time.Sleep(core.IntervalDuration) // 等待下一个周期
+			return err
+		case <-mtCore.reconnect:
+			return errManualReconnect
+		case <-mtCore.Context.Done():
+			return nil
 		}
+	}
+}
 
-	}()
-	return nil
+type coreSummary struct {
+	Name     string             `json:"name"`
+	Target   string             `json:"target"`
+	Host     string             `json:"host"`
+	Port     int                `json:"port"`
+	Interval string             `json:"interval"`
+	Status   CoreStatus         `json:"status"`
+	Health   core.HealthSummary `json:"health"`
+}
+
+func (mws *MonitorWebServer) coreList() []coreSummary {
+	list := []coreSummary{}
+	mws.cores.Range(func(key, value any) bool {
+		mtCore := value.(*MTCore)
+		list = append(list, coreSummary{
+			Name:     key.(string),
+			Target:   mtCore.Address(),
+			Host:     mtCore.Host,
+			Port:     mtCore.Port,
+			Interval: mtCore.Interval,
+			Status:   mtCore.Status(),
+			Health:   mtCore.collector.Health(),
+		})
+		return true
+	})
+	slices.SortFunc(list, func(a, b coreSummary) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return list
 }
 
 func (mws *MonitorWebServer) RemoveCore(name string) error {
@@ -253,6 +489,10 @@ func (mws *MonitorWebServer) RemoveCore(name string) error {
 		if mtCore, ok := core.(*MTCore); ok {
 			mtCore.Cancel()        // 取消处理
 			mws.cores.Delete(name) // 从map中删除
+			mws.Broadcast("", "cores", mws.coreList())
+			if !mtCore.Wait(removeTimeout) {
+				return fmt.Errorf("core %s: %w (%s)", name, ErrStopTimeout, removeTimeout)
+			}
 		}
 		return nil
 	} else {
@@ -276,9 +516,11 @@ func (mws *MonitorWebServer) HandleWebSocket(w http.ResponseWriter, r *http.Requ
 		})
 	}()
 
-	// 添加连接到列表
+	// 先发送当前的核心列表，再加入广播列表
 	mws.shield.Protect(func() {
-		mws.wsconns[conn] = true
+		if err := conn.WriteJSON(gin.H{"name": "", "type": "cores", "data": mws.coreList()}); err == nil {
+			mws.wsconns[conn] = true
+		}
 	})
 
 	// 读取消息循环
@@ -315,9 +557,37 @@ func (mws *MonitorWebServer) Broadcast(name string, dataType string, data any) {
 	})
 }
 
-func (mws *MonitorWebServer) Start(host string, port int) error {
-	mws.render.Run(fmt.Sprintf("%s:%d", host, port))
-	return nil
+// Start 启动服务直到 ctx 取消，取消后停止接收请求并调用 Close
+func (mws *MonitorWebServer) Start(ctx context.Context, host string, port int) error {
+	server := &http.Server{Addr: fmt.Sprintf("%s:%d", host, port), Handler: mws.render}
+	shutdown := make(chan error, 1)
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+		defer cancel()
+		shutdown <- server.Shutdown(shutdownCtx)
+	})
+	defer stop()
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = <-shutdown
+	}
+	return errors.Join(err, mws.Close())
+}
+
+// Close 停止所有核心，核心不再写入后关闭指标存储
+func (mws *MonitorWebServer) Close() error {
+	mws.reloadLock.Lock()
+	defer mws.reloadLock.Unlock()
+	var errs []error
+	mws.cores.Range(func(key, _ any) bool {
+		errs = append(errs, mws.RemoveCore(key.(string)))
+		return true
+	})
+	if mws.store != nil {
+		errs = append(errs, mws.store.Close())
+	}
+	return errors.Join(errs...)
 }
 
 func NewMonitorWebServer(credentials []*Credential, uiFiles fs.FS) *MonitorWebServer {
@@ -345,6 +615,7 @@ func NewMonitorWebServer(credentials []*Credential, uiFiles fs.FS) *MonitorWebSe
 			WriteBufferSize: 1024,
 		},
 		credentials: credentials,
+		connect:     HandleCore,
 	}
 
 	server.SetRoutes(uiFiles)
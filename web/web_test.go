@@ -0,0 +1,563 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/B9O2/monitors/monitor"
+	"github.com/B9O2/mtmonitor/core"
+	"github.com/B9O2/mtmonitor/runtime"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// fakeConn 假核心的一次连接，关闭 metrics 表示数据流结束
+type fakeConn struct {
+	address  string
+	ctx      context.Context
+	metadata map[string]string // 建立连接时的令牌 metadata
+	metrics  chan *core.Metrics
+	events   chan *monitor.Events
+}
+
+// fakeDialer 代替 HandleCore，记录每次连接，err 不为空时连接失败
+type fakeDialer struct {
+	lock  sync.Mutex
+	err   error
+	block chan struct{} // 不为空时连接一直阻塞到通道关闭，并且不理会 ctx
+	conns chan *fakeConn
+}
+
+func (fd *fakeDialer) connect(ctx context.Context, mtCore *MTCore, cred *Credential) (chan *core.Metrics, chan *monitor.Events, error) {
+	fd.lock.Lock()
+	err, block := fd.err, fd.block
+	fd.lock.Unlock()
+	if block != nil {
+		fd.conns <- &fakeConn{address: mtCore.Address(), ctx: ctx}
+		<-block
+		return nil, nil, errors.New("connection released")
+	}
+	if err != nil {
+		fd.conns <- &fakeConn{address: mtCore.Address(), ctx: ctx}
+		return nil, nil, err
+	}
+	conn := &fakeConn{
+		address: mtCore.Address(),
+		ctx:     ctx,
+		metrics: make(chan *core.Metrics),
+		events:  make(chan *monitor.Events),
+	}
+	if perRPC := cred.PerRPCCredentials(); perRPC != nil {
+		md, err := perRPC.GetRequestMetadata(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn.metadata = md
+	}
+	// 与 HandleCore 一致：ctx 取消后关闭数据流
+	mtCore.wg.Add(1)
+	go func() {
+		defer mtCore.wg.Done()
+		<-ctx.Done()
+		close(conn.metrics)
+		close(conn.events)
+	}()
+	fd.conns <- conn
+	return conn.metrics, conn.events, nil
+}
+
+func (fd *fakeDialer) setErr(err error) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	fd.err = err
+}
+
+// hang 之后的连接永远不返回，直到测试结束
+func (fd *fakeDialer) hang(t *testing.T) {
+	fd.lock.Lock()
+	defer fd.lock.Unlock()
+	fd.block = make(chan struct{})
+	t.Cleanup(func() { close(fd.block) })
+}
+
+// next 等待下一次连接
+func (fd *fakeDialer) next(t *testing.T) *fakeConn {
+	t.Helper()
+	select {
+	case conn := <-fd.conns:
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for connection")
+		return nil
+	}
+}
+
+// newTestServer 创建使用假连接的服务，凭证 "c" 不启用 TLS
+func newTestServer(t *testing.T) (*MonitorWebServer, *fakeDialer) {
+	t.Helper()
+	mws := NewMonitorWebServer(NewCredentials(map[string]runtime.CredentialConfig{"c": {}}), fstest.MapFS{})
+	dialer := &fakeDialer{conns: make(chan *fakeConn, 16)}
+	mws.connect = dialer.connect
+	t.Cleanup(func() {
+		mws.cores.Range(func(key, _ any) bool {
+			mws.RemoveCore(key.(string))
+			return true
+		})
+	})
+	return mws, dialer
+}
+
+func testCore(address string) runtime.CoreConfig {
+	host, port, _ := net.SplitHostPort(address)
+	p, _ := strconv.Atoi(port)
+	return runtime.CoreConfig{
+		Host:       host,
+		Port:       p,
+		Interval:   "1s",
+		Credential: "c",
+	}
+}
+
+// wsMessage WebSocket 推送的消息
+type wsMessage struct {
+	Name string          `json:"name"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// listen 连接服务的 WebSocket 并将收到的消息转发到返回的通道
+func listen(t *testing.T, mws *MonitorWebServer) <-chan wsMessage {
+	t.Helper()
+	server := httptest.NewServer(mws.render)
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	messages := make(chan wsMessage, 256)
+	go func() {
+		defer close(messages)
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			messages <- msg
+		}
+	}()
+	// 等待服务端登记连接
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		registered := false
+		mws.shield.Protect(func() { registered = len(mws.wsconns) > 0 })
+		if registered {
+			return messages
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("websocket not registered")
+	return nil
+}
+
+// waitState 等待核心进入指定状态
+func waitState(t *testing.T, mtCore *MTCore, state ConnState) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if mtCore.Status().State == state {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("core state is %s, want %s", mtCore.Status().State, state)
+}
+
+func loadCore(t *testing.T, mws *MonitorWebServer, name string) *MTCore {
+	t.Helper()
+	value, ok := mws.cores.Load(name)
+	if !ok {
+		t.Fatalf("core %s not registered", name)
+	}
+	return value.(*MTCore)
+}
+
+func containsJSON(data []byte, fragment string) bool {
+	return strings.Contains(string(data), fragment)
+}
+
+// untilMessage 读取消息直到满足 match，返回此前收到的消息
+func untilMessage(t *testing.T, messages <-chan wsMessage, match func(wsMessage) bool) []wsMessage {
+	t.Helper()
+	var seen []wsMessage
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				t.Fatal("websocket closed")
+			}
+			if match(msg) {
+				return seen
+			}
+			seen = append(seen, msg)
+		case <-timeout:
+			t.Fatal("timed out waiting for message")
+		}
+	}
+}
+
+// reconnectCore 重连等待很长的核心，只有手动重连才会在测试时间内再次连接
+func reconnectCore(maxAttempts uint) runtime.CoreConfig {
+	cc := testCore("127.0.0.1:9001")
+	cc.Reconnect = runtime.ReconnectConfig{InitialDelay: "1h", MaxDelay: "1h", MaxAttempts: maxAttempts}
+	return cc
+}
+
+func TestManualReconnectWhileStreaming(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	messages := listen(t, mws)
+	if err := mws.AddCore("a", reconnectCore(0)); err != nil {
+		t.Fatal(err)
+	}
+	first := dialer.next(t)
+	mtCore := loadCore(t, mws, "a")
+	waitState(t, mtCore, StateStreaming)
+
+	mtCore.Reconnect()
+	second := dialer.next(t)
+	if first.ctx.Err() == nil {
+		t.Error("old connection not cancelled")
+	}
+	waitState(t, mtCore, StateStreaming)
+	if second.ctx.Err() != nil {
+		t.Error("new connection cancelled")
+	}
+
+	// 手动重连不经过 backoff
+	for {
+		select {
+		case msg := <-messages:
+			if msg.Type == "core_status" && containsJSON(msg.Data, `"state":"backoff"`) {
+				t.Fatalf("manual reconnect went through backoff: %s", msg.Data)
+			}
+			continue
+		default:
+		}
+		break
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts uint
+		state       ConnState
+	}{
+		{"backoff", 0, StateBackoff},
+		{"failed after max attempts", 1, StateFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mws, dialer := newTestServer(t)
+			dialer.setErr(errors.New("connection refused"))
+			if err := mws.AddCore("a", reconnectCore(tt.maxAttempts)); err != nil {
+				t.Fatal(err)
+			}
+			dialer.next(t)
+			mtCore := loadCore(t, mws, "a")
+			waitState(t, mtCore, tt.state)
+
+			status := mtCore.Status()
+			if status.LastError != "connection refused" || status.Attempts != 1 {
+				t.Errorf("status = %+v", status)
+			}
+			if tt.state == StateBackoff && (status.NextRetryAt == nil || time.Until(*status.NextRetryAt) < 30*time.Minute) {
+				t.Errorf("next retry at %v, want about 1h from now", status.NextRetryAt)
+			}
+
+			// 手动重连立即重新连接，连接成功后进入 streaming
+			dialer.setErr(nil)
+			mtCore.Reconnect()
+			dialer.next(t)
+			waitState(t, mtCore, StateStreaming)
+			if got := mtCore.Status().Attempts; got != 1 {
+				t.Errorf("attempts after manual reconnect = %d, want 1", got)
+			}
+		})
+	}
+}
+
+// 令牌文件轮换后，打开中的数据流重连并携带新令牌
+func TestTokenRotationReconnects(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mws, dialer := newTestServer(t)
+	mws.credentials = NewCredentials(map[string]runtime.CredentialConfig{
+		"t": {Type: runtime.CredentialToken, TokenFile: tokenFile, Insecure: true},
+	})
+	cc := testCore("127.0.0.1:9001")
+	cc.Interval = "10ms"
+	cc.Credential = "t"
+	cc.HealthCheck.StaleIntervals = 1000
+	if err := mws.AddCore("a", cc); err != nil {
+		t.Fatal(err)
+	}
+	first := dialer.next(t)
+	if got := first.metadata["authorization"]; got != "Bearer old" {
+		t.Fatalf("first connection authorization = %q", got)
+	}
+	waitState(t, loadCore(t, mws, "a"), StateStreaming)
+
+	if err := os.WriteFile(tokenFile, []byte("rotated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	second := dialer.next(t)
+	if got := second.metadata["authorization"]; got != "Bearer rotated" {
+		t.Errorf("second connection authorization = %q", got)
+	}
+	if first.ctx.Err() == nil {
+		t.Error("stream with the old token not cancelled")
+	}
+}
+
+// waitStopped 等待连接的数据流关闭，并确认核心的 goroutine 均已退出
+func waitStopped(t *testing.T, mtCore *MTCore, conn *fakeConn) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-conn.metrics:
+			if ok {
+				continue
+			}
+		case <-timeout:
+			t.Fatalf("stream to %s not closed", conn.address)
+		}
+		break
+	}
+	if !mtCore.Wait(2 * time.Second) {
+		t.Fatalf("goroutines of core at %s still running", conn.address)
+	}
+}
+
+func isStopped(msg wsMessage) bool {
+	return msg.Name == "a" && msg.Type == "core_status" && containsJSON(msg.Data, `"state":"stopped"`)
+}
+
+func TestRemoveAndReAddCore(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	messages := listen(t, mws)
+
+	if err := mws.AddCore("a", testCore("127.0.0.1:9001")); err != nil {
+		t.Fatal(err)
+	}
+	oldConn := dialer.next(t)
+	oldCore := loadCore(t, mws, "a")
+	waitState(t, oldCore, StateStreaming)
+
+	if err := mws.RemoveCore("a"); err != nil {
+		t.Fatal(err)
+	}
+	if oldConn.ctx.Err() == nil || oldCore.Context.Err() == nil {
+		t.Error("connection of the removed core still open")
+	}
+	untilMessage(t, messages, isStopped)
+	waitStopped(t, oldCore, oldConn)
+	if err := mws.RemoveCore("a"); err == nil {
+		t.Error("removing a missing core succeeded")
+	}
+
+	// 同名核心重新添加后，旧核心迟到的 stopped 状态不再推送
+	if err := mws.AddCore("a", testCore("127.0.0.1:9002")); err != nil {
+		t.Fatal(err)
+	}
+	newConn := dialer.next(t)
+	newCore := loadCore(t, mws, "a")
+	waitState(t, newCore, StateStreaming)
+	if newCore == oldCore || newConn.address != "127.0.0.1:9002" {
+		t.Fatal("core not replaced")
+	}
+	oldCore.conn.transition(StateStopped, nil)
+	mws.Broadcast("", "sentinel", nil)
+	for _, msg := range untilMessage(t, messages, func(msg wsMessage) bool { return msg.Type == "sentinel" }) {
+		if isStopped(msg) {
+			t.Errorf("stale status of the replaced core broadcast: %s", msg.Data)
+		}
+	}
+
+	if err := mws.RemoveCore("a"); err != nil {
+		t.Fatal(err)
+	}
+	if newConn.ctx.Err() == nil {
+		t.Error("connection of the re-added core still open")
+	}
+	untilMessage(t, messages, isStopped)
+	waitStopped(t, newCore, newConn)
+}
+
+// 同名核心并发添加时只有一个成功，其余不留下连接或 goroutine
+func TestConcurrentAddCore(t *testing.T) {
+	mws, dialer := newTestServer(t)
+
+	const adds = 32
+	errs := make(chan error, adds)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range adds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs <- mws.AddCore("a", testCore(fmt.Sprintf("127.0.0.1:%d", 9001+i)))
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	var added int
+	for err := range errs {
+		if err == nil {
+			added++
+		}
+	}
+	if added != 1 {
+		t.Fatalf("%d concurrent adds succeeded, want 1", added)
+	}
+
+	conn := dialer.next(t)
+	mtCore := loadCore(t, mws, "a")
+	if got := mtCore.Address(); got != conn.address {
+		t.Errorf("registered core %s, connected core %s", got, conn.address)
+	}
+	select {
+	case extra := <-dialer.conns:
+		t.Errorf("rejected core connected to %s", extra.address)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := mws.RemoveCore("a"); err != nil {
+		t.Fatal(err)
+	}
+	waitStopped(t, mtCore, conn)
+}
+
+// ctx 取消后 Start 停止服务、停止所有核心并关闭存储
+func TestStartShutdown(t *testing.T) {
+	mws, dialer := newTestServer(t)
+	store, err := core.OpenStore("", runtime.StorageConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mws.SetStore(store)
+	if err := mws.AddCore("a", testCore("127.0.0.1:9001")); err != nil {
+		t.Fatal(err)
+	}
+	conn := dialer.next(t)
+	mtCore := loadCore(t, mws, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- mws.Start(ctx, "127.0.0.1", 0) }()
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Start = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return after cancel")
+	}
+	waitStopped(t, mtCore, conn)
+	if cores := mws.coreList(); len(cores) != 0 {
+		t.Errorf("cores after shutdown: %+v", cores)
+	}
+}
+
+// 超过 stale_intervals 个周期没有消息时上报问题、取消连接并重连
+func TestStaleStreamWatchdog(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals uint
+		active    bool
+		staleAt   time.Duration // 最早的重连时间
+	}{
+		{"silent stream", 3, false, 30 * time.Millisecond},
+		{"default stale intervals", 0, false, runtime.DefaultStaleIntervals * 10 * time.Millisecond},
+		{"active stream", 3, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mws, dialer := newTestServer(t)
+			messages := listen(t, mws)
+			cc := testCore("127.0.0.1:9001")
+			cc.Interval = "10ms"
+			cc.HealthCheck.StaleIntervals = tt.intervals
+			started := time.Now()
+			if err := mws.AddCore("a", cc); err != nil {
+				t.Fatal(err)
+			}
+			conn := dialer.next(t)
+			mtCore := loadCore(t, mws, "a")
+			waitState(t, mtCore, StateStreaming)
+
+			if tt.active {
+				// 持续有消息时不会重连
+				deadline := time.After(200 * time.Millisecond)
+				for done := false; !done; {
+					mtCore.conn.touch()
+					select {
+					case <-dialer.conns:
+						t.Fatal("active stream reconnected")
+					case <-deadline:
+						done = true
+					case <-time.After(5 * time.Millisecond):
+					}
+				}
+				if conn.ctx.Err() != nil {
+					t.Error("active stream cancelled")
+				}
+				mws.Broadcast("", "sentinel", nil)
+				for _, msg := range untilMessage(t, messages, func(msg wsMessage) bool { return msg.Type == "sentinel" }) {
+					if msg.Type == "health_issue" {
+						t.Errorf("active stream reported: %s", msg.Data)
+					}
+				}
+				return
+			}
+
+			dialer.next(t)
+			if elapsed := time.Since(started); elapsed < tt.staleAt {
+				t.Errorf("reconnected after %s, want at least %s", elapsed, tt.staleAt)
+			}
+			if conn.ctx.Err() == nil {
+				t.Error("stale connection not cancelled")
+			}
+			untilMessage(t, messages, func(msg wsMessage) bool {
+				return msg.Type == "health_issue" && containsJSON(msg.Data, `"type":"stream-stale"`)
+			})
+			if status := mtCore.Status(); !strings.HasPrefix(status.LastError, "no messages received for ") {
+				t.Errorf("last error %q", status.LastError)
+			}
+		})
+	}
+}